@@ -0,0 +1,107 @@
+package JobStorage
+
+import (
+   "io/ioutil"
+   "os"
+   "path/filepath"
+   "strings"
+   "sync"
+)
+
+const RECORD_EXTENSION = ".json"
+
+/* Storage of the job records (values) by their unique job_id (keys).
+A record is an opaque content (JSON encoded job for instance) that the Store shall keep as is. */
+type Store interface {
+   Save(jobId string, record []byte) error
+   Load(jobId string) ([]byte, error)
+   List() ([]string, error)
+   Delete(jobId string) error
+}
+
+/* File-backed Store:
+- Dir: directory where each job record is stored as a file named by its job_id.*/
+type FileStore struct {
+   sync.Mutex
+   Dir string
+}
+
+
+
+/* FileStore creation.
+This method shall create a FileStore storing its records into the specified directory, creating the directory if not existing yet.
+*/
+func NewFileStore(dir string) (*FileStore, error) {
+   err := os.MkdirAll(dir, 0755)
+   if err != nil {
+      return nil, err
+   }
+   return &FileStore{Dir:dir}, nil
+}
+
+// Helper function to get the path of the file storing the record of the specified job
+func (fileStore *FileStore) recordPath(jobId string) string {
+   return filepath.Join(fileStore.Dir, filepath.Base(jobId) + RECORD_EXTENSION)
+}
+
+/* Saving a job record.
+This method shall write the specified record in the file of the specified job.
+The record shall be written in a temporary file first, then renamed, so that a crash never leaves a partially written record.
+The file shall be readable by its owner only, a record possibly holding the secrets of a job (callback secret for instance).
+*/
+func (fileStore *FileStore) Save(jobId string, record []byte) error {
+   fileStore.Lock()
+   defer fileStore.Unlock()
+
+   recordPath := fileStore.recordPath(jobId)
+   tmpPath := recordPath + ".tmp"
+   err := ioutil.WriteFile(tmpPath, record, 0600)
+   if err != nil {
+      return err
+   }
+   return os.Rename(tmpPath, recordPath)
+}
+
+/* Loading a job record.
+This method shall read and return the record from the file of the specified job.
+*/
+func (fileStore *FileStore) Load(jobId string) ([]byte, error) {
+   fileStore.Lock()
+   defer fileStore.Unlock()
+
+   return ioutil.ReadFile(fileStore.recordPath(jobId))
+}
+
+/* Listing job records.
+This method shall return the job_id of all the job records stored in the directory of the specified receiver FileStore.
+*/
+func (fileStore *FileStore) List() ([]string, error) {
+   fileStore.Lock()
+   defer fileStore.Unlock()
+
+   files, err := ioutil.ReadDir(fileStore.Dir)
+   if err != nil {
+      return nil, err
+   }
+   jobIds := []string{}
+   for _, file := range files {
+      if (!file.IsDir() && strings.HasSuffix(file.Name(), RECORD_EXTENSION)) {
+         jobIds = append(jobIds, strings.TrimSuffix(file.Name(), RECORD_EXTENSION))
+      }
+   }
+   return jobIds, nil
+}
+
+/* Deleting a job record.
+This method shall remove the file of the specified job, if existing.
+*/
+func (fileStore *FileStore) Delete(jobId string) error {
+   fileStore.Lock()
+   defer fileStore.Unlock()
+
+   err := os.Remove(fileStore.recordPath(jobId))
+   if os.IsNotExist(err) {
+      return nil
+   }
+   return err
+}
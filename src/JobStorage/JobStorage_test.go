@@ -0,0 +1,124 @@
+package JobStorage
+
+import (
+   "encoding/json"
+   "io/ioutil"
+   "os"
+   "path/filepath"
+   "reflect"
+   "sort"
+   "testing"
+)
+
+/* Job record of the tests, as the main package saves them */
+type testRecord struct {
+   Def map[string]interface{} `json:"def"`
+   Status map[string]interface{} `json:"status"`
+   Result map[string]map[string][]string `json:"result"`
+}
+
+// Helper function to create a FileStore in a temporary directory removed at the end of the test
+func newTestStore(t *testing.T) *FileStore {
+   dir, err := ioutil.TempDir("", "jobstore")
+   if err != nil {
+      t.Fatal(err)
+   }
+   t.Cleanup(func() { os.RemoveAll(dir) })
+   fileStore, err := NewFileStore(filepath.Join(dir, "jobs"))
+   if err != nil {
+      t.Fatal(err)
+   }
+   return fileStore
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+   fileStore := newTestStore(t)
+   jobs := map[string]*testRecord{
+      "1": {
+         Def:map[string]interface{}{"job_id":"1", "urls":[]interface{}{"http://example.com/"}, "workers":float64(2)},
+         Status:map[string]interface{}{"state":"completed", "completed":float64(1)},
+         Result:map[string]map[string][]string{"images":{"http://example.com/":{"http://example.com/a.png"}}},
+      },
+      "2": {
+         Def:map[string]interface{}{"job_id":"2", "urls":[]interface{}{"http://example.org/"}},
+         Status:map[string]interface{}{"state":"running"},
+         Result:map[string]map[string][]string{},
+      },
+   }
+   for jobId, job := range jobs {
+      record, _ := json.Marshal(job)
+      if err := fileStore.Save(jobId, record); err != nil {
+         t.Fatalf("Save(%q): %v", jobId, err)
+      }
+   }
+   // Saving again replacing the previous record
+   jobs["2"].Status["state"] = "cancelled"
+   record, _ := json.Marshal(jobs["2"])
+   if err := fileStore.Save("2", record); err != nil {
+      t.Fatalf("Save(\"2\") again: %v", err)
+   }
+
+   // Reloading from another store on the same directory, as done at the server start
+   reloaded, err := NewFileStore(fileStore.Dir)
+   if err != nil {
+      t.Fatal(err)
+   }
+   jobIds, err := reloaded.List()
+   sort.Strings(jobIds)
+   if (err != nil) || !reflect.DeepEqual(jobIds, []string{"1", "2"}) {
+      t.Fatalf("List() = %v, %v, want [1 2]", jobIds, err)
+   }
+   for _, jobId := range jobIds {
+      record, err := reloaded.Load(jobId)
+      if err != nil {
+         t.Errorf("Load(%q): %v", jobId, err)
+         continue
+      }
+      job := &testRecord{}
+      if err := json.Unmarshal(record, job); err != nil {
+         t.Errorf("Load(%q): %v", jobId, err)
+         continue
+      }
+      if !reflect.DeepEqual(job, jobs[jobId]) {
+         t.Errorf("Load(%q) = %+v, want %+v", jobId, job, jobs[jobId])
+      }
+   }
+
+   // Record files readable by their owner only, without temporary file left
+   files, _ := ioutil.ReadDir(fileStore.Dir)
+   for _, file := range files {
+      if filepath.Ext(file.Name()) != RECORD_EXTENSION {
+         t.Errorf("file %s left in the store", file.Name())
+      }
+      if file.Mode().Perm() != 0600 {
+         t.Errorf("record file %s mode %v, want %v", file.Name(), file.Mode().Perm(), os.FileMode(0600))
+      }
+   }
+
+   // Deleting a record, twice
+   for i := 0; i < 2; i++ {
+      if err := reloaded.Delete("1"); err != nil {
+         t.Errorf("Delete(\"1\"): %v", err)
+      }
+   }
+   if _, err := reloaded.Load("1"); err == nil {
+      t.Error("Load(\"1\") once deleted: error expected")
+   }
+   if jobIds, _ := reloaded.List(); !reflect.DeepEqual(jobIds, []string{"2"}) {
+      t.Errorf("List() once deleted = %v, want [2]", jobIds)
+   }
+}
+
+func TestFileStoreRecordPath(t *testing.T) {
+   // Job ids not escaping the store directory
+   fileStore := newTestStore(t)
+   for _, jobId := range []string{"../escaped", "dir/job"} {
+      if err := fileStore.Save(jobId, []byte("{}")); err != nil {
+         t.Errorf("Save(%q): %v", jobId, err)
+         continue
+      }
+      if dir := filepath.Dir(fileStore.recordPath(jobId)); dir != fileStore.Dir {
+         t.Errorf("record of %q saved in %s, want %s", jobId, dir, fileStore.Dir)
+      }
+   }
+}
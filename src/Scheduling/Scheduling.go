@@ -0,0 +1,347 @@
+package Scheduling
+
+import (
+   "errors"
+   "strconv"
+   "strings"
+   "sync"
+   "time"
+)
+
+const EVERY_PREFIX = "@every "
+const MIN_INTERVAL = time.Second // Minimum interval between two runs of an interval expression
+const MAX_CRON_YEARS = 5 // Number of years looked through for the next time matching a cron expression
+
+// Overlap policies, deciding what to do at a tick when the run spawned before is not over
+const OVERLAP_SKIP = "skip"
+const OVERLAP_QUEUE = "queue"
+const OVERLAP_ALLOW = "allow"
+
+/* Cron expressions of the supported aliases */
+var CRON_ALIASES = map[string]string{
+   "@yearly": "0 0 1 1 *",
+   "@annually": "0 0 1 1 *",
+   "@monthly": "0 0 1 * *",
+   "@weekly": "0 0 * * 0",
+   "@daily": "0 0 * * *",
+   "@midnight": "0 0 * * *",
+   "@hourly": "0 * * * *",
+}
+
+/* Schedule expression, giving the time of the next run strictly after the specified time */
+type Expression interface {
+   Next(after time.Time) time.Time
+}
+
+/* Interval expression: "@every <duration>", the runs being spaced by the duration */
+type intervalExpression struct {
+   interval time.Duration
+}
+
+/* Cron expression: "<minute> <hour> <day of month> <month> <day of week>", as the allowed values of each field.
+As for cron, a time matches the days if it matches the days of month or the days of week when both are restricted (not exactly "*"). */
+type cronExpression struct {
+   minutes map[int]bool
+   hours map[int]bool
+   daysOfMonth map[int]bool
+   months map[int]bool
+   daysOfWeek map[int]bool
+   anyDayOfMonth bool
+   anyDayOfWeek bool
+}
+
+/* Runner of a schedule, spawning a run at each tick of its expression according to its overlap policy:
+- last: channel closed when the run spawned last is over, nil if none,
+- queued: whether a run is queued, waiting for the last one to be over,
+- stop: channel closed when the runner is stopped,
+- ticking: lock serializing the handling of the ticks, so that the spawn and skip functions are called without holding the runner lock, which
+  their callers may take while holding their own locks (see Queued).*/
+type Runner struct {
+   sync.Mutex
+   ticking sync.Mutex
+   expression Expression
+   policy string
+   spawn func() <-chan struct{}
+   skip func()
+   last <-chan struct{}
+   queued bool
+   stop chan struct{}
+   stopOnce sync.Once
+}
+
+
+
+/* Expression parsing.
+This method shall parse the specified schedule expression, or return an error if incorrect:
+- "@every <duration>": interval expression, the duration being given as "90s", "1h30m"..., at least one second,
+- "@yearly", "@monthly", "@weekly", "@daily", "@hourly"...: aliases of the matching cron expressions,
+- "<minute> <hour> <day of month> <month> <day of week>": cron expression, each field being "*", a value, a range "a-b", a range followed by a step
+  "/n" ("*", "a-b" or "a" as range, up to the maximum for the latter), or a comma separated list of them; Sunday being 0 or 7 as day of week.
+*/
+func ParseExpression(expression string) (Expression, error) {
+   expression = strings.TrimSpace(expression)
+   if strings.HasPrefix(expression, EVERY_PREFIX) {
+      interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, EVERY_PREFIX)))
+      if err != nil {
+         return nil, err
+      }
+      if interval < MIN_INTERVAL {
+         return nil, errors.New("interval shall be at least " + MIN_INTERVAL.String())
+      }
+      return &intervalExpression{interval:interval}, nil
+   }
+   if alias, existing := CRON_ALIASES[expression]; existing {
+      expression = alias
+   }
+
+   fields := strings.Fields(expression)
+   if len(fields) != 5 {
+      return nil, errors.New("cron expression shall have 5 fields: " + expression)
+   }
+   cron := &cronExpression{}
+   var err error
+   if cron.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+      return nil, err
+   }
+   if cron.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+      return nil, err
+   }
+   if cron.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+      return nil, err
+   }
+   if cron.months, err = parseCronField(fields[3], 1, 12); err != nil {
+      return nil, err
+   }
+   if cron.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+      return nil, err
+   }
+   if cron.daysOfWeek[7] {
+      cron.daysOfWeek[0] = true
+   }
+   // Only a bare "*" leaves the days unrestricted, "*/2" restricting them as any other list of values
+   cron.anyDayOfMonth = fields[2] == "*"
+   cron.anyDayOfWeek = fields[4] == "*"
+   return cron, nil
+}
+
+// Helper function to parse a cron field into its allowed values, between the specified minimum and maximum
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+   values := map[int]bool{}
+   for _, part := range strings.Split(field, ",") {
+      rangePart, step := part, 1
+      if slash := strings.Index(part, "/"); slash >= 0 {
+         var err error
+         rangePart = part[:slash]
+         if step, err = strconv.Atoi(part[slash + 1:]); (err != nil) || (step < 1) {
+            return nil, errors.New("incorrect cron step: " + part)
+         }
+      }
+      first, last := min, max
+      if rangePart != "*" {
+         bounds := strings.SplitN(rangePart, "-", 2)
+         var err error
+         if first, err = strconv.Atoi(bounds[0]); err != nil {
+            return nil, errors.New("incorrect cron value: " + part)
+         }
+         last = first
+         if len(bounds) == 2 {
+            if last, err = strconv.Atoi(bounds[1]); err != nil {
+               return nil, errors.New("incorrect cron range: " + part)
+            }
+         } else if step > 1 {
+            // "a/n": from a to the maximum
+            last = max
+         }
+      }
+      if (first < min) || (last > max) || (first > last) {
+         return nil, errors.New("cron value out of range: " + part)
+      }
+      for value := first; value <= last; value += step {
+         values[value] = true
+      }
+   }
+   return values, nil
+}
+
+/* Next time of an interval expression.
+This method shall return the specified time plus the interval of the specified receiver expression.
+*/
+func (expression *intervalExpression) Next(after time.Time) time.Time {
+   return after.Add(expression.interval)
+}
+
+// Helper function to check whether the specified day matches a cron expression: both day fields if one of them is "*", either of them otherwise
+func (expression *cronExpression) matchDay(day time.Time) bool {
+   dayOfMonth := expression.daysOfMonth[day.Day()]
+   dayOfWeek := expression.daysOfWeek[int(day.Weekday())]
+   if expression.anyDayOfMonth || expression.anyDayOfWeek {
+      return dayOfMonth && dayOfWeek
+   }
+   return dayOfMonth || dayOfWeek
+}
+
+/* Next time of a cron expression.
+This method shall return the first minute strictly after the specified time matching the specified receiver expression, in the location of the
+specified time, or the zero time if none within the next years.
+*/
+func (expression *cronExpression) Next(after time.Time) time.Time {
+   next := after.Truncate(time.Minute).Add(time.Minute)
+   limit := next.AddDate(MAX_CRON_YEARS, 0, 0)
+   for next.Before(limit) {
+      switch {
+         case !expression.months[int(next.Month())]:
+            // Jumping to the first minute of the next month
+            next = time.Date(next.Year(), next.Month() + 1, 1, 0, 0, 0, 0, next.Location())
+         case !expression.matchDay(next):
+            next = time.Date(next.Year(), next.Month(), next.Day() + 1, 0, 0, 0, 0, next.Location())
+         case !expression.hours[next.Hour()]:
+            next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour() + 1, 0, 0, 0, next.Location())
+         case !expression.minutes[next.Minute()]:
+            next = next.Add(time.Minute)
+         default:
+            return next
+      }
+   }
+   return time.Time{}
+}
+
+/* Overlap policy checking.
+This method shall return an error if the specified overlap policy is not one of skip, queue and allow.
+*/
+func CheckOverlap(policy string) error {
+   if (policy != OVERLAP_SKIP) && (policy != OVERLAP_QUEUE) && (policy != OVERLAP_ALLOW) {
+      return errors.New("unknown overlap policy: " + policy)
+   }
+   return nil
+}
+
+/* Runner creation.
+This method shall create the runner of a schedule of the specified expression and overlap policy, which run spawned last is over once the specified
+last channel is closed (none if nil). At each tick, the specified spawn function shall be called to spawn a run, returning the channel closed once
+the run is over; the specified skip function shall be called when a tick is skipped.
+*/
+func NewRunner(expression Expression, policy string, last <-chan struct{}, spawn func() <-chan struct{}, skip func()) *Runner {
+   return &Runner{expression:expression, policy:policy, last:last, spawn:spawn, skip:skip, stop:make(chan struct{})}
+}
+
+/* Next tick.
+This method shall return the time of the next tick of the specified receiver runner after the specified time, the zero time if none.
+*/
+func (runner *Runner) Next(after time.Time) time.Time {
+   return runner.expression.Next(after)
+}
+
+/* Runner in action.
+This method shall wait for each tick of the expression of the specified receiver runner, and handle it, until the runner is stopped.
+The specified next function, if any, shall be called with the time of each next tick.
+*/
+func (runner *Runner) Run(next func(time.Time)) {
+   for {
+      select {
+         case <-runner.stop:
+            return
+         default:
+      }
+      tick := runner.expression.Next(time.Now())
+      if next != nil {
+         next(tick)
+      }
+      if tick.IsZero() {
+         return
+      }
+      timer := time.NewTimer(time.Until(tick))
+      select {
+         case <-timer.C:
+            runner.Tick()
+         case <-runner.stop:
+            timer.Stop()
+            return
+      }
+   }
+}
+
+/* Handling a tick.
+This method shall spawn a run of the specified receiver runner, unless the run spawned last is not over yet; in this case according to the overlap policy:
+- skip: the tick shall be skipped,
+- queue: the run shall be spawned once the last one is over; a tick while a run is already queued shall be skipped,
+- allow: the run shall be spawned anyway.
+*/
+func (runner *Runner) Tick() {
+   runner.ticking.Lock()
+   defer runner.ticking.Unlock()
+
+   // Deciding under the runner lock, spawning or skipping once released
+   runner.Lock()
+   spawn := (runner.policy == OVERLAP_ALLOW) || !isRunning(runner.last)
+   skip := !spawn && ((runner.policy == OVERLAP_SKIP) || runner.queued)
+   if !spawn && !skip {
+      // Queuing the run until the last one is over
+      runner.queued = true
+      go runner.spawnQueued(runner.last)
+   }
+   runner.Unlock()
+
+   switch {
+      case spawn:
+         runner.spawnRun()
+      case skip:
+         runner.skip()
+   }
+}
+
+// Helper function to spawn the queued run of the specified receiver runner once the specified last run is over, unless the runner is stopped meanwhile
+func (runner *Runner) spawnQueued(last <-chan struct{}) {
+   select {
+      case <-last:
+      case <-runner.stop:
+         return
+   }
+   runner.ticking.Lock()
+   defer runner.ticking.Unlock()
+   runner.Lock()
+   runner.queued = false
+   runner.Unlock()
+   select {
+      case <-runner.stop:
+         // Stopped meanwhile
+      default:
+         runner.spawnRun()
+   }
+}
+
+// Helper function to spawn a run of the specified receiver runner, recorded as the last one; called with the ticks handling locked
+func (runner *Runner) spawnRun() {
+   last := runner.spawn()
+   runner.Lock()
+   runner.last = last
+   runner.Unlock()
+}
+
+/* Checking queued run.
+This method shall return whether a run of the specified receiver runner is queued, waiting for the last one to be over.
+*/
+func (runner *Runner) Queued() bool {
+   runner.Lock()
+   defer runner.Unlock()
+   return runner.queued
+}
+
+/* Stopping the runner.
+This method shall stop the specified receiver runner: no run shall be spawned anymore, including a queued one.
+*/
+func (runner *Runner) Stop() {
+   runner.stopOnce.Do(func() { close(runner.stop) })
+}
+
+// Helper function to check whether a run is not over yet, from its channel closed once over
+func isRunning(done <-chan struct{}) bool {
+   if done == nil {
+      return false
+   }
+   select {
+      case <-done:
+         return false
+      default:
+         return true
+   }
+}
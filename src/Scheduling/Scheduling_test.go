@@ -0,0 +1,135 @@
+package Scheduling
+
+import (
+   "testing"
+   "time"
+)
+
+func TestParseExpressionNext(t *testing.T) {
+   // Monday, January 1st 2024
+   monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+   tests := []struct {
+      expression string
+      after time.Time
+      want time.Time
+   }{
+      {"30 2 * * *", monday, time.Date(2024, 1, 1, 2, 30, 0, 0, time.UTC)},
+      {"*/15 * * * *", monday, time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)},
+      {"0 9-17/4 * * *", monday, time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+      {"0 0 13 * *", monday, time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)},
+      {"0 0 * * 5", monday, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+      {"0 0 * * 7", monday, time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+      {"0 0 1 1 *", monday, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+      {"0 0 * 3 *", monday, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+      {"0 0 29 2 *", monday, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+      {"0 0 31 2 *", monday, time.Time{}},
+      // Steps on the day fields restrict the days
+      {"0 0 */2 * *", monday, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+      {"0 0 * * */2", monday, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+      {"0 0 * * */2", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+      // Both day fields restricted: either of them matching
+      {"0 0 13 * 5", monday, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+      {"0 0 2-7 * 1", monday, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+      {"0 0 */10 * */3", monday, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+      // Aliases and intervals
+      {"@hourly", monday, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)},
+      {"@weekly", monday, time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+      {"@monthly", monday, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+      {"@every 90s", monday, time.Date(2024, 1, 1, 0, 1, 30, 0, time.UTC)},
+   }
+   for _, test := range tests {
+      expression, err := ParseExpression(test.expression)
+      if err != nil {
+         t.Errorf("ParseExpression(%q): unexpected error %v", test.expression, err)
+         continue
+      }
+      if got := expression.Next(test.after); !got.Equal(test.want) {
+         t.Errorf("ParseExpression(%q).Next(%v) = %v, want %v", test.expression, test.after, got, test.want)
+      }
+   }
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+   for _, expression := range []string{
+      "",
+      "* * * *",
+      "* * * * * *",
+      "60 * * * *",
+      "* 24 * * *",
+      "* * 0 * *",
+      "* * * 13 *",
+      "* * * * 8",
+      "*/0 * * * *",
+      "5-1 * * * *",
+      "a * * * *",
+      "1-a * * * *",
+      "@every 500ms",
+      "@every soon",
+      "@often",
+   } {
+      if _, err := ParseExpression(expression); err == nil {
+         t.Errorf("ParseExpression(%q): error expected", expression)
+      }
+   }
+}
+
+func TestRunnerTick(t *testing.T) {
+   tests := []struct {
+      policy string
+      running bool
+      spawned int
+      skipped int
+   }{
+      {OVERLAP_SKIP, false, 1, 0},
+      {OVERLAP_SKIP, true, 0, 1},
+      {OVERLAP_ALLOW, true, 1, 0},
+      {OVERLAP_QUEUE, true, 0, 0},
+   }
+   for _, test := range tests {
+      last := make(chan struct{})
+      if !test.running {
+         close(last)
+      }
+      spawned, skipped := 0, 0
+      var runner *Runner
+      // The spawn and skip functions read the runner, as the schedules end points do
+      runner = NewRunner(&intervalExpression{interval:time.Hour}, test.policy, last,
+         func() <-chan struct{} { runner.Queued(); spawned++; return make(chan struct{}) },
+         func() { runner.Queued(); skipped++ })
+      runner.Tick()
+      if (spawned != test.spawned) || (skipped != test.skipped) {
+         t.Errorf("%s policy, running %v: spawned %d skipped %d, want %d and %d", test.policy, test.running, spawned, skipped, test.spawned,
+            test.skipped)
+      }
+      runner.Stop()
+   }
+}
+
+func TestRunnerQueue(t *testing.T) {
+   last := make(chan struct{})
+   spawns := make(chan struct{}, 2)
+   skipped := 0
+   var runner *Runner
+   runner = NewRunner(&intervalExpression{interval:time.Hour}, OVERLAP_QUEUE, last,
+      func() <-chan struct{} { runner.Queued(); spawns <- struct{}{}; return make(chan struct{}) },
+      func() { skipped++ })
+   defer runner.Stop()
+
+   runner.Tick()
+   if !runner.Queued() {
+      t.Fatal("run not queued while the last one is running")
+   }
+   runner.Tick()
+   if skipped != 1 {
+      t.Errorf("tick while a run is queued: skipped %d, want 1", skipped)
+   }
+   close(last)
+   select {
+      case <-spawns:
+      case <-time.After(5 * time.Second):
+         t.Fatal("queued run not spawned once the last one is over")
+   }
+   if runner.Queued() {
+      t.Error("run still queued once spawned")
+   }
+}
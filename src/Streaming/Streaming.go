@@ -0,0 +1,132 @@
+package Streaming
+
+import (
+   "bytes"
+   "encoding/json"
+   "fmt"
+   "io"
+   "sync"
+)
+
+const SUBSCRIBER_BUFFER = 256 // Number of events waiting to be sent to a subscriber before it is dropped as too slow
+
+/* Event published to the subscribers of a broker: sequence number, name, and JSON encoded data */
+type Event struct {
+   Id int
+   Name string
+   Data []byte
+}
+
+/* Broker of the events of a source (a job for instance), broadcasting them to its subscribers:
+- subscribers: channels of the subscribers, each receiving all the events published after its subscription,
+- seq: sequence number of the last published event,
+- closed: whether the broker is closed, no more event being to come.*/
+type Broker struct {
+   sync.Mutex
+   subscribers map[chan Event]bool
+   seq int
+   closed bool
+}
+
+
+
+/* Broker creation.
+This method shall create a broker without any subscriber.
+*/
+func NewBroker() *Broker {
+   return &Broker{subscribers:map[chan Event]bool{}}
+}
+
+/* Checking subscribers.
+This method shall return whether the specified receiver broker has subscribers, so that the publishers can skip computing events nobody receives.
+*/
+func (broker *Broker) Active() bool {
+   broker.Lock()
+   defer broker.Unlock()
+   return len(broker.subscribers) > 0
+}
+
+/* Publishing an event.
+This method shall send the specified event, with its data JSON encoded, to all the subscribers of the specified receiver broker, without waiting for them:
+a subscriber which buffer is full shall be dropped, its channel being closed. Nothing shall be published without subscribers or once closed.
+*/
+func (broker *Broker) Publish(name string, data interface{}) {
+   broker.Lock()
+   defer broker.Unlock()
+   if (len(broker.subscribers) == 0) || broker.closed {
+      return
+   }
+   encoded, err := json.Marshal(data)
+   if err != nil {
+      fmt.Println("ERROR: Failed to publish " + name + " event: " + err.Error())
+      return
+   }
+   broker.seq++
+   event := Event{Id:broker.seq, Name:name, Data:encoded}
+   for subscriber := range broker.subscribers {
+      select {
+         case subscriber <- event:
+         default:
+            // Subscriber too slow: dropping it rather than blocking the publisher
+            delete(broker.subscribers, subscriber)
+            close(subscriber)
+      }
+   }
+}
+
+/* Subscribing.
+This method shall return a channel receiving the events published by the specified receiver broker from now on, closed once the broker is closed
+or the subscriber dropped, and the function ending the subscription. The channel shall be closed already if the broker is closed.
+*/
+func (broker *Broker) Subscribe() (<-chan Event, func()) {
+   broker.Lock()
+   defer broker.Unlock()
+   subscriber := make(chan Event, SUBSCRIBER_BUFFER)
+   if broker.closed {
+      close(subscriber)
+      return subscriber, func() {}
+   }
+   broker.subscribers[subscriber] = true
+   return subscriber, func() {
+      broker.Lock()
+      defer broker.Unlock()
+      if broker.subscribers[subscriber] {
+         delete(broker.subscribers, subscriber)
+         close(subscriber)
+      }
+   }
+}
+
+/* Closing the broker.
+This method shall close the channels of all the subscribers of the specified receiver broker, once they received the events already published.
+No event shall be published after.
+*/
+func (broker *Broker) Close() {
+   broker.Lock()
+   defer broker.Unlock()
+   broker.closed = true
+   for subscriber := range broker.subscribers {
+      delete(broker.subscribers, subscriber)
+      close(subscriber)
+   }
+}
+
+/* Writing a Server-Sent Event.
+This method shall write the specified event to the specified writer in the text/event-stream format: its id (none if 0, for the events not published
+by a broker), its name, and its data, one "data:" field per line.
+*/
+func WriteEvent(w io.Writer, event Event) error {
+   var buffer bytes.Buffer
+   if event.Id != 0 {
+      fmt.Fprintf(&buffer, "id: %d\n", event.Id)
+   }
+   fmt.Fprintf(&buffer, "event: %s\n", event.Name)
+   for _, line := range bytes.Split(event.Data, []byte("\n")) {
+      buffer.WriteString("data: ")
+      buffer.Write(line)
+      buffer.WriteString("\n")
+   }
+   buffer.WriteString("\n")
+   _, err := w.Write(buffer.Bytes())
+   return err
+}
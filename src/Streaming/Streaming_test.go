@@ -0,0 +1,142 @@
+package Streaming
+
+import (
+   "bytes"
+   "reflect"
+   "testing"
+)
+
+// Helper function to read the events already sent to the specified subscriber, and return them with whether its channel is closed
+func receiveEvents(subscriber <-chan Event) ([]Event, bool) {
+   events := []Event{}
+   for {
+      select {
+         case event, open := <-subscriber:
+            if !open {
+               return events, true
+            }
+            events = append(events, event)
+         default:
+            return events, false
+      }
+   }
+}
+
+func TestBrokerSubscribe(t *testing.T) {
+   broker := NewBroker()
+   if broker.Active() {
+      t.Error("broker without subscriber active")
+   }
+   // Event without subscriber not published
+   broker.Publish("lost", 0)
+
+   first, unsubscribeFirst := broker.Subscribe()
+   broker.Publish("one", map[string]int{"n":1})
+   second, unsubscribeSecond := broker.Subscribe()
+   broker.Publish("two", "text")
+   if !broker.Active() {
+      t.Error("broker with subscribers not active")
+   }
+
+   // Every subscriber receiving the events published since its subscription, in order
+   tests := []struct {
+      subscriber <-chan Event
+      want []Event
+   }{
+      {first, []Event{{Id:1, Name:"one", Data:[]byte(`{"n":1}`)}, {Id:2, Name:"two", Data:[]byte(`"text"`)}}},
+      {second, []Event{{Id:2, Name:"two", Data:[]byte(`"text"`)}}},
+   }
+   for i, test := range tests {
+      if events, closed := receiveEvents(test.subscriber); !reflect.DeepEqual(events, test.want) || closed {
+         t.Errorf("subscriber %d: events %v, closed %v, want %v, open", i, events, closed, test.want)
+      }
+   }
+
+   // Unsubscribing closing the channel of the subscriber only, twice without harm
+   unsubscribeFirst()
+   unsubscribeFirst()
+   broker.Publish("three", 3)
+   if events, closed := receiveEvents(first); (len(events) != 0) || !closed {
+      t.Errorf("unsubscribed subscriber: events %v, closed %v, want none, closed", events, closed)
+   }
+   if events, closed := receiveEvents(second); (len(events) != 1) || (events[0].Id != 3) || closed {
+      t.Errorf("remaining subscriber: events %v, closed %v, want event 3, open", events, closed)
+   }
+   unsubscribeSecond()
+   if broker.Active() {
+      t.Error("broker active once all its subscribers unsubscribed")
+   }
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+   broker := NewBroker()
+   slow, unsubscribeSlow := broker.Subscribe()
+   defer unsubscribeSlow()
+   fast, unsubscribeFast := broker.Subscribe()
+   defer unsubscribeFast()
+
+   // Slow subscriber never reading: dropped once its buffer is full, the fast one reading all the events
+   received := 0
+   for i := 0; i <= SUBSCRIBER_BUFFER; i++ {
+      broker.Publish("event", i)
+      events, closed := receiveEvents(fast)
+      if closed {
+         t.Fatalf("fast subscriber dropped after %d events", received)
+      }
+      received += len(events)
+   }
+   if received != SUBSCRIBER_BUFFER + 1 {
+      t.Errorf("fast subscriber received %d events, want %d", received, SUBSCRIBER_BUFFER + 1)
+   }
+   if events, closed := receiveEvents(slow); (len(events) != SUBSCRIBER_BUFFER) || !closed {
+      t.Errorf("slow subscriber: %d events, closed %v, want %d, closed", len(events), closed, SUBSCRIBER_BUFFER)
+   }
+   broker.Lock()
+   subscribers := len(broker.subscribers)
+   broker.Unlock()
+   if subscribers != 1 {
+      t.Errorf("%d subscribers left, want the fast one only", subscribers)
+   }
+}
+
+func TestBrokerClose(t *testing.T) {
+   broker := NewBroker()
+   subscriber, unsubscribe := broker.Subscribe()
+   broker.Publish("last", "end")
+   broker.Close()
+
+   // Events published before the end received, then the channel closed
+   want := []Event{{Id:1, Name:"last", Data:[]byte(`"end"`)}}
+   if events, closed := receiveEvents(subscriber); !reflect.DeepEqual(events, want) || !closed {
+      t.Errorf("events %v, closed %v, want %v, closed", events, closed, want)
+   }
+   // Nothing published after the end, unsubscribing after the end without harm
+   unsubscribe()
+   broker.Publish("after", "end")
+   if broker.Active() {
+      t.Error("closed broker active")
+   }
+
+   // Subscribing after the end: channel closed already
+   late, unsubscribeLate := broker.Subscribe()
+   defer unsubscribeLate()
+   if events, closed := receiveEvents(late); (len(events) != 0) || !closed {
+      t.Errorf("subscriber after the end: events %v, closed %v, want none, closed", events, closed)
+   }
+}
+
+func TestWriteEvent(t *testing.T) {
+   tests := []struct {
+      event Event
+      want string
+   }{
+      {Event{Id:3, Name:"url", Data:[]byte(`{"url":"http://example.com/"}`)}, "id: 3\nevent: url\ndata: {\"url\":\"http://example.com/\"}\n\n"},
+      {Event{Name:"status", Data:[]byte("a\nb")}, "event: status\ndata: a\ndata: b\n\n"},
+   }
+   for _, test := range tests {
+      var buffer bytes.Buffer
+      if err := WriteEvent(&buffer, test.event); (err != nil) || (buffer.String() != test.want) {
+         t.Errorf("WriteEvent(%v) wrote %q, %v, want %q", test.event, buffer.String(), err, test.want)
+      }
+   }
+}
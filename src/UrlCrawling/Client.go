@@ -0,0 +1,303 @@
+package UrlCrawling
+
+import (
+   "context"
+   "crypto/sha256"
+   "encoding/hex"
+   "errors"
+   "io"
+   "net"
+   "net/http"
+   "net/http/cookiejar"
+   "net/url"
+   "sort"
+   "strconv"
+   "strings"
+   "time"
+)
+
+var errBodyTooLarge = errors.New("response body too large")
+var errTooManyRedirects = errors.New("too many redirects")
+var errCrossHostRedirect = errors.New("redirect to another host")
+
+/* Settings of the HTTP client of a job:
+- ConnectTimeout: maximum duration of the connection to a host, TLS handshake included, none if 0,
+- ReadTimeout: maximum duration waiting for the response headers, then between two reads of the response body, none if 0,
+- TotalTimeout: maximum duration of a request, from the connection to the end of the response body, none if 0,
+- MaxRedirects: maximum number of redirects followed by a request, 0 not to follow any,
+- CrossHostRedirects: whether the redirects to another host than the requested one are followed,
+- UserAgent: User-Agent header of the requests, the Go default one if empty,
+- Headers: headers (values) by name (keys) added to the requests, without replacing those set by the crawler itself (Range, validators...),
+- Cookies: initial cookies of the cookie jar of the client,
+- Seeds: Job URLs which hosts receive the initial cookies without domain.*/
+type ClientSettings struct {
+   ConnectTimeout time.Duration
+   ReadTimeout time.Duration
+   TotalTimeout time.Duration
+   MaxRedirects int
+   CrossHostRedirects bool
+   UserAgent string
+   Headers map[string]string
+   Cookies []*InitialCookie
+   Seeds []string
+}
+
+/* Cookie sent from the first request of a job:
+- name, value: name and value of the cookie,
+- domain: host receiving the cookie, with its subdomains, the hosts of the Job URLs only if empty,
+- path: path prefix of the URLs receiving the cookie, all if empty.*/
+type InitialCookie struct {
+   Name string `json:"name"`
+   Value string `json:"value"`
+   Domain string `json:"domain,omitempty"`
+   Path string `json:"path,omitempty"`
+}
+
+/* Transport adding the user agent and headers of a job to its requests */
+type headerTransport struct {
+   transport *http.Transport
+   userAgent string
+   headers http.Header
+}
+
+/* Connection which reads shall not last more than a read timeout */
+type readTimeoutConn struct {
+   net.Conn
+   timeout time.Duration
+}
+
+/* Reader of a response body failing once more than a maximum number of bytes is read */
+type limitedReader struct {
+   reader io.Reader
+   remaining int64
+}
+
+/* Redirect not followed, with its cause and detail */
+type redirectError struct {
+   err error
+   detail string
+}
+
+
+
+/* HTTP client creation.
+This method shall create an HTTP client with its own connections and cookie jar, honouring the specified settings.
+A request exceeding one of the timeouts shall fail with a timeout error, and a redirect not to be followed with a redirect error.
+The cookie jar shall start with the initial cookies, and keep the cookies set by the responses for the next requests of the client.
+*/
+func NewHttpClient(settings *ClientSettings) *http.Client {
+   dialer := &net.Dialer{Timeout:settings.ConnectTimeout, KeepAlive:30 * time.Second}
+   transport := http.DefaultTransport.(*http.Transport).Clone()
+   transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+      conn, err := dialer.DialContext(ctx, network, address)
+      if (err != nil) || (settings.ReadTimeout == 0) {
+         return conn, err
+      }
+      return &readTimeoutConn{Conn:conn, timeout:settings.ReadTimeout}, nil
+   }
+   transport.TLSHandshakeTimeout = settings.ConnectTimeout
+   transport.ResponseHeaderTimeout = settings.ReadTimeout
+
+   var roundTripper http.RoundTripper = transport
+   if (settings.UserAgent != "") || (len(settings.Headers) > 0) {
+      headers := http.Header{}
+      for name, value := range settings.Headers {
+         headers.Set(name, value)
+      }
+      roundTripper = &headerTransport{transport:transport, userAgent:settings.UserAgent, headers:headers}
+   }
+
+   jar, _ := cookiejar.New(nil)
+   for _, cookie := range settings.Cookies {
+      cookie.setIn(jar, settings.Seeds)
+   }
+
+   maxRedirects := settings.MaxRedirects
+   crossHostRedirects := settings.CrossHostRedirects
+   return &http.Client{
+      Transport:roundTripper,
+      Jar:jar,
+      Timeout:settings.TotalTimeout,
+      CheckRedirect:func(request *http.Request, via []*http.Request) error {
+         if len(via) > maxRedirects {
+            return &redirectError{err:errTooManyRedirects, detail:"more than " + strconv.Itoa(maxRedirects)}
+         }
+         if !crossHostRedirects && (request.URL.Host != via[0].URL.Host) {
+            return &redirectError{err:errCrossHostRedirect, detail:request.URL.Host}
+         }
+         return nil
+      },
+   }
+}
+
+/* Checking the request identity of a job.
+This method shall return an error if the specified user agent, headers (values) by name (keys) or initial cookies cannot be sent: invalid header name
+or value, Cookie header instead of cookies, invalid cookie name, value, domain or path.
+*/
+func CheckIdentity(userAgent string, headers map[string]string, cookies []*InitialCookie) error {
+   if !validHeaderValue(userAgent) {
+      return errors.New("invalid user agent: " + strconv.Quote(userAgent))
+   }
+   for name, value := range headers {
+      if !validToken(name) {
+         return errors.New("invalid header name: " + strconv.Quote(name))
+      }
+      if !validHeaderValue(value) {
+         return errors.New("invalid value of header " + name + ": " + strconv.Quote(value))
+      }
+      if http.CanonicalHeaderKey(name) == "Cookie" {
+         return errors.New("Cookie header not allowed: use cookies instead")
+      }
+   }
+   for _, cookie := range cookies {
+      if (cookie == nil) || !validToken(cookie.Name) {
+         return errors.New("invalid cookie name")
+      }
+      for _, char := range []byte(cookie.Value) {
+         if (char < 0x20) || (char >= 0x7f) || (char == '"') || (char == ';') || (char == '\\') {
+            return errors.New("invalid value of cookie " + cookie.Name + ": " + strconv.Quote(cookie.Value))
+         }
+      }
+      if strings.ContainsAny(cookie.Domain, "/:; ") {
+         return errors.New("invalid domain of cookie " + cookie.Name + ": " + strconv.Quote(cookie.Domain))
+      }
+      if (cookie.Path != "") && (!strings.HasPrefix(cookie.Path, "/") || strings.ContainsAny(cookie.Path, "; ")) {
+         return errors.New("invalid path of cookie " + cookie.Name + ": " + strconv.Quote(cookie.Path))
+      }
+   }
+   return nil
+}
+
+/* Identity of the requests.
+This method shall return a digest of the user agent, headers and initial cookies of the specified receiver settings, so that the pages fetched with
+different ones are not shared by the fetch and robots.txt caches, or an empty string if none of them is specified.
+*/
+func (settings *ClientSettings) Identity() string {
+   if (settings.UserAgent == "") && (len(settings.Headers) == 0) && (len(settings.Cookies) == 0) {
+      return ""
+   }
+   lines := []string{}
+   for name, value := range settings.Headers {
+      lines = append(lines, "H " + http.CanonicalHeaderKey(name) + ": " + value)
+   }
+   for _, cookie := range settings.Cookies {
+      lines = append(lines, "C " + cookie.Domain + " " + cookie.Path + " " + cookie.Name + "=" + cookie.Value)
+   }
+   sort.Strings(lines)
+   digest := sha256.Sum256([]byte("U " + settings.UserAgent + "\n" + strings.Join(lines, "\n")))
+   return hex.EncodeToString(digest[:8])
+}
+
+// Helper function to check whether the specified string is a token (RFC 7230), as header and cookie names shall be
+func validToken(token string) bool {
+   if token == "" {
+      return false
+   }
+   for _, char := range []byte(token) {
+      if (char <= 0x20) || (char >= 0x7f) || strings.IndexByte(`"(),/:;<=>?@[\]{}`, char) >= 0 {
+         return false
+      }
+   }
+   return true
+}
+
+// Helper function to check whether the specified string can be sent as a header value: no control character but tabs
+func validHeaderValue(value string) bool {
+   for _, char := range []byte(value) {
+      if ((char < 0x20) && (char != '\t')) || (char == 0x7f) {
+         return false
+      }
+   }
+   return true
+}
+
+// Helper function to set the specified receiver initial cookie in the specified jar: for its domain if any, else for the hosts of the specified seeds
+func (cookie *InitialCookie) setIn(jar http.CookieJar, seeds []string) {
+   path := cookie.Path
+   if path == "" {
+      path = "/"
+   }
+   if cookie.Domain != "" {
+      domain := strings.TrimPrefix(cookie.Domain, ".")
+      for _, scheme := range []string{"http", "https"} {
+         jar.SetCookies(&url.URL{Scheme:scheme, Host:domain, Path:path},
+            []*http.Cookie{{Name:cookie.Name, Value:cookie.Value, Domain:domain, Path:path}})
+      }
+      return
+   }
+   for _, seed := range seeds {
+      seedUrl, err := url.Parse(seed)
+      if (err != nil) || (seedUrl.Host == "") {
+         continue
+      }
+      jar.SetCookies(&url.URL{Scheme:seedUrl.Scheme, Host:seedUrl.Host, Path:path}, []*http.Cookie{{Name:cookie.Name, Value:cookie.Value, Path:path}})
+   }
+}
+
+// Implementation of http.RoundTripper by the header transports, adding the user agent and headers of the job to a copy of the request
+func (transport *headerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+   request = request.Clone(request.Context())
+   for name, values := range transport.headers {
+      if _, set := request.Header[name]; !set {
+         request.Header[name] = values
+      }
+   }
+   if (transport.userAgent != "") && (request.Header.Get("User-Agent") == "") {
+      request.Header.Set("User-Agent", transport.userAgent)
+   }
+   return transport.transport.RoundTrip(request)
+}
+
+func (transport *headerTransport) CloseIdleConnections() {
+   transport.transport.CloseIdleConnections()
+}
+
+// Implementation of error by the redirect errors, unwrapping to their cause
+func (err *redirectError) Error() string {
+   return err.err.Error() + ": " + err.detail
+}
+
+func (err *redirectError) Unwrap() error {
+   return err.err
+}
+
+// Implementation of net.Conn by the read timeout connections, the deadline of each read being set from the read timeout
+func (conn *readTimeoutConn) Read(buffer []byte) (int, error) {
+   conn.Conn.SetReadDeadline(time.Now().Add(conn.timeout))
+   return conn.Conn.Read(buffer)
+}
+
+// Helper function to limit the specified response body to the maximum body size of the job, if any
+func (urlProcess *UrlProcess) limitBody(body io.Reader) io.Reader {
+   if urlProcess.Config.MaxBodySize <= 0 {
+      return body
+   }
+   return &limitedReader{reader:body, remaining:urlProcess.Config.MaxBodySize}
+}
+
+// Implementation of io.Reader by the limited readers, failing with errBodyTooLarge once the maximum is exceeded
+func (reader *limitedReader) Read(buffer []byte) (int, error) {
+   if reader.remaining <= 0 {
+      // Maximum reached: checking whether the body goes on
+      var probe [1]byte
+      length, err := reader.reader.Read(probe[:])
+      if length > 0 {
+         return 0, errBodyTooLarge
+      }
+      return 0, err
+   }
+   if int64(len(buffer)) > reader.remaining {
+      buffer = buffer[:reader.remaining]
+   }
+   length, err := reader.reader.Read(buffer)
+   reader.remaining -= int64(length)
+   return length, err
+}
+
+// Helper function to get the HTTP client of the job, the default one if none
+func (urlProcess *UrlProcess) client() *http.Client {
+   if urlProcess.Config.Client == nil {
+      return http.DefaultClient
+   }
+   return urlProcess.Config.Client
+}
@@ -0,0 +1,271 @@
+package UrlCrawling
+
+import (
+   "errors"
+   "io/ioutil"
+   "net"
+   "net/http"
+   "net/http/httptest"
+   "strconv"
+   "strings"
+   "testing"
+   "time"
+)
+
+// Helper function to request the specified URL with the specified client, and return the class of its failure, empty if the body is read entirely
+func requestClass(client *http.Client, requestUrl string) string {
+   response, err := client.Get(requestUrl)
+   if err != nil {
+      return classifyError(err)
+   }
+   defer response.Body.Close()
+   if _, err := ioutil.ReadAll(response.Body); err != nil {
+      return classifyError(err)
+   }
+   return ""
+}
+
+func TestNewHttpClientRedirects(t *testing.T) {
+   other := newTestServer(t, map[string]string{"/": "other host"})
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      switch {
+         case r.URL.Path == "/cross":
+            http.Redirect(w, r, other.URL + "/", http.StatusFound)
+         case strings.HasPrefix(r.URL.Path, "/hops/"):
+            // Redirecting to /hops/{n-1} until /hops/0
+            hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hops/"))
+            if hops > 0 {
+               http.Redirect(w, r, "/hops/" + strconv.Itoa(hops - 1), http.StatusFound)
+               return
+            }
+            w.Write([]byte("ok"))
+         default:
+            http.NotFound(w, r)
+      }
+   }))
+   t.Cleanup(server.Close)
+
+   tests := []struct {
+      path string
+      maxRedirects int
+      crossHost bool
+      want string
+   }{
+      {"/hops/0", 0, true, ""},
+      {"/hops/1", 0, true, FAILURE_REDIRECT},
+      {"/hops/3", 3, true, ""},
+      {"/hops/4", 3, true, FAILURE_REDIRECT},
+      {"/cross", 10, true, ""},
+      {"/cross", 10, false, FAILURE_REDIRECT},
+      {"/cross", 0, true, FAILURE_REDIRECT},
+   }
+   for _, test := range tests {
+      client := NewHttpClient(&ClientSettings{MaxRedirects:test.maxRedirects, CrossHostRedirects:test.crossHost})
+      if got := requestClass(client, server.URL + test.path); got != test.want {
+         t.Errorf("%s with max_redirects %d, cross_host_redirects %v: failure %q, want %q", test.path, test.maxRedirects, test.crossHost, got,
+            test.want)
+      }
+   }
+}
+
+func TestNewHttpClientTimeouts(t *testing.T) {
+   // Pages stalling for a while before their headers, or in the middle of their body
+   stall := 500 * time.Millisecond
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      if r.URL.Path == "/slow-headers" {
+         select {
+            case <-time.After(stall):
+            case <-r.Context().Done():
+               return
+         }
+      }
+      w.Write([]byte("first part"))
+      w.(http.Flusher).Flush()
+      if r.URL.Path == "/slow-body" {
+         select {
+            case <-time.After(stall):
+            case <-r.Context().Done():
+               return
+         }
+      }
+      w.Write([]byte("last part"))
+   }))
+   t.Cleanup(server.Close)
+
+   timeout := 100 * time.Millisecond
+   tests := []struct {
+      path string
+      settings *ClientSettings
+      want string
+   }{
+      {"/", &ClientSettings{ReadTimeout:timeout, TotalTimeout:timeout}, ""},
+      {"/slow-headers", &ClientSettings{}, ""},
+      {"/slow-headers", &ClientSettings{ReadTimeout:timeout}, FAILURE_TIMEOUT},
+      {"/slow-body", &ClientSettings{ReadTimeout:timeout}, FAILURE_TIMEOUT},
+      {"/slow-body", &ClientSettings{TotalTimeout:timeout}, FAILURE_TIMEOUT},
+      {"/slow-body", &ClientSettings{ReadTimeout:2 * stall}, ""},
+   }
+   for _, test := range tests {
+      if got := requestClass(NewHttpClient(test.settings), server.URL + test.path); got != test.want {
+         t.Errorf("%s with %+v: failure %q, want %q", test.path, test.settings, got, test.want)
+      }
+   }
+}
+
+func TestNewHttpClientConnectTimeout(t *testing.T) {
+   // Host accepting the connections, but never answering the TLS handshake
+   listener, err := net.Listen("tcp", "127.0.0.1:0")
+   if err != nil {
+      t.Fatal(err)
+   }
+   t.Cleanup(func() { listener.Close() })
+   go func() {
+      conns := []net.Conn{}
+      for {
+         conn, err := listener.Accept()
+         if err != nil {
+            for _, conn := range conns {
+               conn.Close()
+            }
+            return
+         }
+         conns = append(conns, conn)
+      }
+   }()
+
+   client := NewHttpClient(&ClientSettings{ConnectTimeout:100 * time.Millisecond})
+   start := time.Now()
+   if got := requestClass(client, "https://" + listener.Addr().String() + "/"); (got != FAILURE_TIMEOUT) || (time.Since(start) > 5 * time.Second) {
+      t.Errorf("TLS handshake never answered: failure %q after %v, want %q", got, time.Since(start), FAILURE_TIMEOUT)
+   }
+}
+
+func TestLimitBody(t *testing.T) {
+   tests := []struct {
+      body string
+      maxBodySize int64
+      tooLarge bool
+   }{
+      {"0123456789", 0, false},
+      {"0123456789", 10, false},
+      {"0123456789", 9, true},
+      {"", 1, false},
+   }
+   for _, test := range tests {
+      urlProcess := &UrlProcess{Config:&CrawlConfig{MaxBodySize:test.maxBodySize}}
+      content, err := ioutil.ReadAll(urlProcess.limitBody(strings.NewReader(test.body)))
+      if errors.Is(err, errBodyTooLarge) != test.tooLarge {
+         t.Errorf("%d bytes body, maximum %d: error %v, want too large %v", len(test.body), test.maxBodySize, err, test.tooLarge)
+      }
+      if (err == nil) && (string(content) != test.body) {
+         t.Errorf("%d bytes body, maximum %d: read %q", len(test.body), test.maxBodySize, content)
+      }
+      if (err != nil) && (int64(len(content)) > test.maxBodySize) {
+         t.Errorf("%d bytes body, maximum %d: %d bytes read", len(test.body), test.maxBodySize, len(content))
+      }
+   }
+}
+
+func TestNewHttpClientIdentity(t *testing.T) {
+   // Page echoing the user agent, the X-Test header and the cookies of the requests, and setting a cookie
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      cookies := []string{}
+      for _, cookie := range r.Cookies() {
+         cookies = append(cookies, cookie.Name + "=" + cookie.Value)
+      }
+      http.SetCookie(w, &http.Cookie{Name:"set", Value:"byserver", Path:"/"})
+      w.Write([]byte(r.Header.Get("User-Agent") + "|" + r.Header.Get("X-Test") + "|" + strings.Join(cookies, ";")))
+   }))
+   t.Cleanup(server.Close)
+   // Same server reached through another host name
+   otherHost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+   settings := &ClientSettings{
+      UserAgent:"TestBot/1.0",
+      Headers:map[string]string{"x-test":"job"},
+      Cookies:[]*InitialCookie{{Name:"seed", Value:"a"}, {Name:"private", Value:"b", Path:"/private"}, {Name:"domain", Value:"c", Domain:"localhost"}},
+      Seeds:[]string{server.URL + "/"},
+   }
+   client := NewHttpClient(settings)
+   tests := []struct {
+      url string
+      header string
+      want string
+   }{
+      {server.URL + "/", "", "TestBot/1.0|job|seed=a"},
+      // Cookie set by the previous response kept, header set by the crawler itself not replaced
+      {server.URL + "/private/page", "own", "TestBot/1.0|own|private=b;seed=a;set=byserver"},
+      {otherHost + "/", "", "TestBot/1.0|job|domain=c"},
+   }
+   for _, test := range tests {
+      request, _ := http.NewRequest(http.MethodGet, test.url, nil)
+      if test.header != "" {
+         request.Header.Set("X-Test", test.header)
+      }
+      response, err := client.Do(request)
+      if err != nil {
+         t.Errorf("%s: unexpected error %v", test.url, err)
+         continue
+      }
+      body, _ := ioutil.ReadAll(response.Body)
+      response.Body.Close()
+      if got := string(body); got != test.want {
+         t.Errorf("%s: request received as %q, want %q", test.url, got, test.want)
+      }
+      if request.Header.Get("User-Agent") != "" {
+         t.Errorf("%s: request of the caller modified", test.url)
+      }
+   }
+
+   // Default client: Go user agent, no header nor cookie
+   response, err := NewHttpClient(&ClientSettings{}).Get(server.URL + "/")
+   if err != nil {
+      t.Fatal(err)
+   }
+   body, _ := ioutil.ReadAll(response.Body)
+   response.Body.Close()
+   if got := string(body); !strings.HasPrefix(got, "Go-http-client/") || !strings.HasSuffix(got, "||") {
+      t.Errorf("default client request received as %q", got)
+   }
+}
+
+func TestCheckIdentity(t *testing.T) {
+   tests := []struct {
+      userAgent string
+      headers map[string]string
+      cookies []*InitialCookie
+      valid bool
+   }{
+      {"", nil, nil, true},
+      {"TestBot/1.0 (+http://example.com/bot)", map[string]string{"Authorization":"Bearer x", "Accept-Language":"fr"},
+         []*InitialCookie{{Name:"session", Value:"abc", Domain:".example.com", Path:"/app"}}, true},
+      {"Bot\r\nX-Injected: 1", nil, nil, false},
+      {"", map[string]string{"Bad Name":"x"}, nil, false},
+      {"", map[string]string{"X-Test":"a\nb"}, nil, false},
+      {"", map[string]string{"cookie":"session=abc"}, nil, false},
+      {"", nil, []*InitialCookie{nil}, false},
+      {"", nil, []*InitialCookie{{Name:"", Value:"abc"}}, false},
+      {"", nil, []*InitialCookie{{Name:"session", Value:"a;b"}}, false},
+      {"", nil, []*InitialCookie{{Name:"session", Value:"abc", Domain:"example.com/path"}}, false},
+      {"", nil, []*InitialCookie{{Name:"session", Value:"abc", Path:"app"}}, false},
+   }
+   for _, test := range tests {
+      if err := CheckIdentity(test.userAgent, test.headers, test.cookies); (err == nil) != test.valid {
+         t.Errorf("CheckIdentity(%q, %v, %v): error %v, want valid %v", test.userAgent, test.headers, test.cookies, err, test.valid)
+      }
+   }
+
+   // Identity: empty by default, the same whatever the order of the headers, different for another cookie
+   identities := map[string]bool{}
+   for _, settings := range []*ClientSettings{
+      {UserAgent:"TestBot", Headers:map[string]string{"A":"1", "B":"2"}},
+      {UserAgent:"TestBot", Headers:map[string]string{"b":"2", "a":"1"}},
+      {UserAgent:"TestBot", Headers:map[string]string{"A":"1", "B":"2"}, Cookies:[]*InitialCookie{{Name:"session", Value:"abc"}}},
+      {UserAgent:"TestBot", Headers:map[string]string{"A":"1", "B":"2"}, Cookies:[]*InitialCookie{{Name:"session", Value:"def"}}},
+   } {
+      identities[settings.Identity()] = true
+   }
+   if (len(identities) != 3) || identities[""] || ((&ClientSettings{}).Identity() != "") {
+      t.Errorf("identities %v, want 3 distinct ones, empty by default only", identities)
+   }
+}
@@ -0,0 +1,232 @@
+package UrlCrawling
+
+import (
+   "context"
+   "errors"
+   "io"
+   "io/ioutil"
+   "net/http"
+   "net/url"
+   "regexp"
+   "strings"
+   "sync"
+)
+
+const MAX_CSS_IMPORT_DEPTH = 3 // Maximum number of nested @import followed from a stylesheet
+const MAX_STYLESHEET_SIZE = 2 << 20 // Maximum number of bytes read from a stylesheet
+
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+var errUnexpectedStatus = errors.New("unexpected HTTP status")
+
+/* Regular expressions of the CSS comments, @import rules and url() references */
+var cssCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+var cssImportRegexp = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)|"([^"]*)"|'([^']*)')[^;]*;?`)
+var cssUrlRegexp = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)`)
+
+
+
+// Helper function to get the first non empty captured group of a regular expression match
+func firstSubmatch(match []string) string {
+   for _, group := range match[1:] {
+      if group != "" {
+         return group
+      }
+   }
+   return ""
+}
+
+/* CSS references parsing.
+This method shall return the paths referenced by the specified CSS content, comments excluded:
+- the url() references, as images candidates,
+- the @import rules, as stylesheets to import.
+*/
+func ParseCssReferences(css string) (urls []string, imports []string) {
+   css = cssCommentRegexp.ReplaceAllString(css, "")
+   for _, match := range cssImportRegexp.FindAllStringSubmatch(css, -1) {
+      if imported := firstSubmatch(match); imported != "" {
+         imports = append(imports, imported)
+      }
+   }
+   css = cssImportRegexp.ReplaceAllString(css, "")
+   for _, match := range cssUrlRegexp.FindAllStringSubmatch(css, -1) {
+      if referenced := firstSubmatch(match); referenced != "" {
+         urls = append(urls, referenced)
+      }
+   }
+   return urls, imports
+}
+
+// Helper function to check whether a <link> tag references a stylesheet
+func isStylesheetLink(relValue string) bool {
+   for _, rel := range strings.Fields(strings.ToLower(relValue)) {
+      if rel == "stylesheet" {
+         return true
+      }
+   }
+   return false
+}
+
+/* Stylesheet fetched for a job: ready is closed once its own images and its @import rules are extracted */
+type stylesheetEntry struct {
+   ready chan struct{}
+   images []string
+   imports []*url.URL
+}
+
+/* Cache of the stylesheets fetched for a job, shared by the processing info of all its URLs:
+- entries: images and imports (values) of the stylesheets (keys) fetched or being fetched, the ones which could not be fetched being removed.*/
+type StylesheetCache struct {
+   sync.Mutex
+   entries map[string]*stylesheetEntry
+}
+
+
+
+/* Stylesheet cache creation.
+This method shall create the stylesheet cache of a job, without any stylesheet.
+*/
+func NewStylesheetCache() *StylesheetCache {
+   return &StylesheetCache{entries:map[string]*stylesheetEntry{}}
+}
+
+/* Getting CSS images.
+This method shall return the absolute URLs of the images, of one of the image types of the job, referenced by the specified CSS content:
+- the url() references shall be resolved against the specified base URL (page or stylesheet URL),
+- the @import rules shall be resolved against the specified base URL and the imported stylesheets fetched, with their own imports, unless already
+  part of the specified chain of stylesheets importing the CSS content, and up to a maximum import depth.
+*/
+func (urlProcess *UrlProcess) CssImages(ctx context.Context, css string, baseUrl *url.URL, importChain []string) []string {
+   images, imports := urlProcess.parseCss(ctx, css, baseUrl)
+   return append(images, urlProcess.importedImages(ctx, imports, importChain)...)
+}
+
+// Helper function to get the absolute URLs of the images of the job image types referenced by a CSS content, and of the stylesheets it imports
+func (urlProcess *UrlProcess) parseCss(ctx context.Context, css string, baseUrl *url.URL) ([]string, []*url.URL) {
+   images := []string{}
+   imports := []*url.URL{}
+   cssUrls, cssImports := ParseCssReferences(css)
+   for _, cssUrl := range cssUrls {
+      parsedUrl, errParse := ParseUrl(&cssUrl)
+      if errParse != nil {
+         continue
+      }
+      imgAbs := baseUrl.ResolveReference(parsedUrl)
+      if urlProcess.MatchImageType(ctx, imgAbs) {
+         images = append(images, imgAbs.String())
+      }
+   }
+   for _, cssImport := range cssImports {
+      parsedUrl, errParse := ParseUrl(&cssImport)
+      if errParse != nil {
+         continue
+      }
+      importUrl := baseUrl.ResolveReference(parsedUrl)
+      importUrl.Fragment = ""
+      imports = append(imports, importUrl)
+   }
+   return images, imports
+}
+
+// Helper function to get the images of imported stylesheets, skipping the cyclic imports and the ones beyond the maximum import depth
+func (urlProcess *UrlProcess) importedImages(ctx context.Context, imports []*url.URL, importChain []string) []string {
+   images := []string{}
+   if len(importChain) >= MAX_CSS_IMPORT_DEPTH {
+      return images
+   }
+   ImportsLoop:
+   for _, importUrl := range imports {
+      for _, importing := range importChain {
+         if importing == importUrl.String() {
+            continue ImportsLoop
+         }
+      }
+      images = append(images, urlProcess.StylesheetImages(ctx, importUrl, importChain)...)
+   }
+   return images
+}
+
+/* Getting stylesheet images.
+This method shall fetch the specified stylesheet as an asset, through the shared host limiter and if allowed by robots.txt, and return the absolute
+URLs of the images it references, resolved against the stylesheet URL, including the ones of the stylesheets it imports.
+The specified import chain shall list the stylesheets importing the specified stylesheet, empty for a stylesheet linked by a page.
+Each stylesheet shall be fetched once for the job, its images and imports being cached in the stylesheet cache of the job, so that a stylesheet
+shared by several pages, of any seed, is not fetched again; each stylesheet being fetched every time without stylesheet cache. A stylesheet which
+could not be fetched shall not be cached, so that it is fetched again when referenced by another page.
+*/
+func (urlProcess *UrlProcess) StylesheetImages(ctx context.Context, sheetUrl *url.URL, importChain []string) []string {
+   sheetUrl.Fragment = ""
+   cache := urlProcess.Config.Stylesheets
+   var entry *stylesheetEntry
+   existing := false
+   if cache != nil {
+      cache.Lock()
+      entry, existing = cache.entries[sheetUrl.String()]
+      if !existing {
+         entry = &stylesheetEntry{ready:make(chan struct{})}
+         cache.entries[sheetUrl.String()] = entry
+      }
+      cache.Unlock()
+   } else {
+      entry = &stylesheetEntry{ready:make(chan struct{})}
+   }
+
+   if existing {
+      // Waiting for the stylesheet being fetched by another worker
+      select {
+         case <-entry.ready:
+         case <-ctx.Done():
+            return []string{}
+      }
+   } else {
+      fetched := false
+      if (sheetUrl.Scheme == "http") || (sheetUrl.Scheme == "https") {
+         if css, err := urlProcess.fetchStylesheet(ctx, sheetUrl); err == nil {
+            entry.images, entry.imports = urlProcess.parseCss(ctx, css, sheetUrl)
+            fetched = true
+         }
+      }
+      if !fetched && (cache != nil) {
+         // Stylesheet not fetched: forgetting it, the workers waiting for it getting no image
+         cache.Lock()
+         delete(cache.entries, sheetUrl.String())
+         cache.Unlock()
+      }
+      close(entry.ready)
+   }
+
+   images := append([]string{}, entry.images...)
+   return append(images, urlProcess.importedImages(ctx, entry.imports, append(importChain[:len(importChain):len(importChain)], sheetUrl.String()))...)
+}
+
+// Helper function to fetch the content of a stylesheet, honouring robots.txt and the host limiter
+func (urlProcess *UrlProcess) fetchStylesheet(ctx context.Context, sheetUrl *url.URL) (string, error) {
+   robotsRules, err := GetRobots(ctx, urlProcess.client(), urlProcess.Config.Identity, sheetUrl)
+   if err != nil {
+      return "", err
+   }
+   if !robotsRules.Allowed(urlProcess.Config.RobotsAgent, sheetUrl) {
+      urlProcess.skipByRobots(sheetUrl.String())
+      return "", errRobotsDisallowed
+   }
+   release, err := SharedHostLimiter.Acquire(ctx, sheetUrl.Host, urlProcess.Config.HostDelay, urlProcess.Config.HostMaxConcurrent)
+   if err != nil {
+      return "", err
+   }
+   defer release()
+
+   request, err := http.NewRequestWithContext(ctx, http.MethodGet, sheetUrl.String(), nil)
+   if err != nil {
+      return "", err
+   }
+   response, err := urlProcess.client().Do(request)
+   if err != nil {
+      return "", err
+   }
+   defer response.Body.Close()
+   if response.StatusCode != http.StatusOK {
+      return "", errUnexpectedStatus
+   }
+   content, err := ioutil.ReadAll(io.LimitReader(response.Body, MAX_STYLESHEET_SIZE))
+   urlProcess.countDownloaded(len(content))
+   return string(content), err
+}
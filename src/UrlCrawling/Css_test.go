@@ -0,0 +1,132 @@
+package UrlCrawling
+
+import (
+   "context"
+   "net/http"
+   "net/http/httptest"
+   "net/url"
+   "reflect"
+   "sync"
+   "testing"
+)
+
+func TestParseCssReferences(t *testing.T) {
+   tests := []struct {
+      css string
+      urls []string
+      imports []string
+   }{
+      {"", nil, nil},
+      {"body { background: url(a.png) }", []string{"a.png"}, nil},
+      {`div { background: url("b.png"), url('c.png'), url( d.png ) }`, []string{"b.png", "c.png", "d.png"}, nil},
+      {"DIV { BACKGROUND: URL(E.PNG) }", []string{"E.PNG"}, nil},
+      {`div { background: url("with space.png") }`, []string{"with space.png"}, nil},
+      {"div { background: url() }", nil, nil},
+      {"/* url(commented.png) */ div { background: url(kept.png) /* @import 'x.css'; */ }", []string{"kept.png"}, nil},
+      {"/* multi\nline url(commented.png)\n*/", nil, nil},
+      {`@import "s.css"; @import 't.css' screen; @import url(u.css); @import url("v.css") print; @IMPORT URL('w.css');`, nil,
+         []string{"s.css", "t.css", "u.css", "v.css", "w.css"}},
+      {`@import url(sheet.css); .logo { background-image: url(data:image/png;base64,AAAA) }`, []string{"data:image/png;base64,AAAA"},
+         []string{"sheet.css"}},
+   }
+   for _, test := range tests {
+      urls, imports := ParseCssReferences(test.css)
+      if !reflect.DeepEqual(urls, test.urls) || !reflect.DeepEqual(imports, test.imports) {
+         t.Errorf("ParseCssReferences(%q) = %q, %q, want %q, %q", test.css, urls, imports, test.urls, test.imports)
+      }
+   }
+}
+
+func TestIsStylesheetLink(t *testing.T) {
+   tests := map[string]bool{
+      "stylesheet": true,
+      "Stylesheet": true,
+      "alternate stylesheet": true,
+      " stylesheet ": true,
+      "icon": false,
+      "preload": false,
+      "stylesheets": false,
+      "": false,
+   }
+   for rel, want := range tests {
+      if got := isStylesheetLink(rel); got != want {
+         t.Errorf("isStylesheetLink(%q) = %v, want %v", rel, got, want)
+      }
+   }
+}
+
+func TestStylesheetImagesCache(t *testing.T) {
+   // Stylesheets referencing an image, /broken.css failing while broken is set
+   var lock sync.Mutex
+   requests := map[string]int{}
+   broken := true
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      lock.Lock()
+      requests[r.URL.Path]++
+      failing := broken && (r.URL.Path == "/broken.css")
+      lock.Unlock()
+      switch {
+         case failing:
+            w.WriteHeader(http.StatusServiceUnavailable)
+         case (r.URL.Path == "/style.css") || (r.URL.Path == "/broken.css"):
+            w.Write([]byte("body { background: url(bg.png) }"))
+         default:
+            http.NotFound(w, r)
+      }
+   }))
+   t.Cleanup(server.Close)
+   image := server.URL + "/bg.png"
+
+   // Processing info of two seeds of a same job, sharing its stylesheet cache
+   config := &CrawlConfig{ImageTypes:NewImageTypes([]string{"png"}), Stylesheets:NewStylesheetCache()}
+   seeds := []*UrlProcess{{}, {}}
+   frontier, _ := NewFrontier("")
+   for i, urlProcess := range seeds {
+      seed := server.URL + "/" + string(rune('a' + i))
+      urlProcess.InitUrlProcess(&seed, config, frontier)
+   }
+   tests := []struct {
+      path string
+      seed int
+      images []string
+      requests int
+   }{
+      {"/style.css", 0, []string{image}, 1},
+      // Stylesheet fetched once for the job, whatever the seed
+      {"/style.css", 1, []string{image}, 1},
+      // Stylesheet not fetched: fetched again
+      {"/broken.css", 0, []string{}, 1},
+      {"/broken.css", 1, []string{}, 2},
+   }
+   for i, test := range tests {
+      sheetUrl, _ := url.Parse(server.URL + test.path)
+      images := seeds[test.seed].StylesheetImages(context.Background(), sheetUrl, nil)
+      lock.Lock()
+      got := requests[test.path]
+      lock.Unlock()
+      if !reflect.DeepEqual(images, test.images) || (got != test.requests) {
+         t.Errorf("request %d of %s: images %v after %d requests, want %v after %d", i, test.path, images, got, test.images, test.requests)
+      }
+   }
+
+   // Stylesheet fetched once again available
+   lock.Lock()
+   broken = false
+   lock.Unlock()
+   sheetUrl, _ := url.Parse(server.URL + "/broken.css")
+   if images := seeds[0].StylesheetImages(context.Background(), sheetUrl, nil); !reflect.DeepEqual(images, []string{image}) {
+      t.Errorf("stylesheet fetched again: images %v, want %v", images, []string{image})
+   }
+
+   // Without stylesheet cache: fetched every time
+   uncached := &UrlProcess{Config:&CrawlConfig{ImageTypes:NewImageTypes([]string{"png"})}}
+   sheetUrl, _ = url.Parse(server.URL + "/style.css")
+   for i := 0; i < 2; i++ {
+      uncached.StylesheetImages(context.Background(), sheetUrl, nil)
+   }
+   lock.Lock()
+   defer lock.Unlock()
+   if requests["/style.css"] != 3 {
+      t.Errorf("stylesheet without cache fetched %d times in all, want 3", requests["/style.css"])
+   }
+}
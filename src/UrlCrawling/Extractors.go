@@ -0,0 +1,311 @@
+package UrlCrawling
+
+import (
+   "context"
+   "errors"
+   "golang.org/x/net/html"
+   "net/url"
+   "path"
+   "strings"
+)
+
+const IMAGES_EXTRACTOR = "images"
+const SCRIPTS_EXTRACTOR = "scripts"
+const STYLESHEETS_EXTRACTOR = "stylesheets"
+const MEDIA_EXTRACTOR = "media"
+const DOCUMENTS_EXTRACTOR = "documents"
+
+/* Attributes of the <img> tags giving an image path, including the lazy-loading ones */
+var IMAGE_SRC_ATTRIBUTES = []string{"src", "data-src", "data-lazy-src", "data-original"}
+
+/* Attributes of the <img> and <source> tags giving a srcset, including the lazy-loading ones */
+var IMAGE_SRCSET_ATTRIBUTES = []string{"srcset", "data-srcset", "data-lazy-srcset"}
+
+/* Extractors used by default when a job does not specify any */
+var DEFAULT_EXTRACTORS = []string{IMAGES_EXTRACTOR}
+
+/* Extensions of the downloadable documents */
+var DOCUMENT_EXTENSIONS = map[string]bool{
+   "pdf": true, "doc": true, "docx": true, "xls": true, "xlsx": true, "ppt": true, "pptx": true, "odt": true, "ods": true, "odp": true,
+   "rtf": true, "csv": true, "epub": true, "zip": true, "gz": true, "tar": true, "7z": true, "rar": true,
+}
+
+/* Record extracted from a crawled page:
+- Value: extracted data, the absolute URL of a resource for the built-in extractors,
+- Detail: additional information on the data (srcset descriptor, tag, document type...), empty if none.*/
+type Record struct {
+   Value string
+   Detail string
+}
+
+/* Crawled page as given to the extractors:
+- Url: final URL of the page, base of its relative references,
+- Depth: number of hops from the reference URL to the page,
+- Process: processing info of the reference URL which the page belongs to,
+- openTags: number of currently open tags (values) per tag name (keys), for the tags which content matters to the extractors.*/
+type Page struct {
+   Url *url.URL
+   Depth int
+   Process *UrlProcess
+   openTags map[string]int
+}
+
+/* Extractor of data from the crawled pages.
+Extract shall be called for each start tag, self-closing tag, and text token inside a <style> tag of a crawled page, in the page order,
+and return the records found in this token.*/
+type Extractor interface {
+   Name() string
+   Extract(ctx context.Context, page *Page, token html.Token) []Record
+}
+
+/* Built-in extractors (values) by name (keys) */
+var EXTRACTORS = map[string]Extractor{
+   IMAGES_EXTRACTOR: &ImagesExtractor{},
+   SCRIPTS_EXTRACTOR: &ScriptsExtractor{},
+   STYLESHEETS_EXTRACTOR: &StylesheetsExtractor{},
+   MEDIA_EXTRACTOR: &MediaExtractor{},
+   DOCUMENTS_EXTRACTOR: &DocumentsExtractor{},
+}
+
+/* Images referenced by <img> tags (src, srcset and lazy-loading attributes), by <source> tags of <picture> tags, and by CSS */
+type ImagesExtractor struct{}
+
+/* Scripts referenced by <script> tags */
+type ScriptsExtractor struct{}
+
+/* Stylesheets referenced by <link> tags and by the @import rules of <style> tags */
+type StylesheetsExtractor struct{}
+
+/* Video and audio sources, tracks and posters referenced by <video> and <audio> tags and their <source> and <track> tags */
+type MediaExtractor struct{}
+
+/* Downloadable documents (pdf, office documents, archives...) referenced by <a> tags */
+type DocumentsExtractor struct{}
+
+
+
+/* Extractors selection.
+This method shall return the built-in extractors of the specified names, or the default extractors if none specified.
+An error shall be returned if one of the names is not a built-in extractor.
+*/
+func NewExtractors(names []string) ([]Extractor, error) {
+   if len(names) == 0 {
+      names = DEFAULT_EXTRACTORS
+   }
+   extractors := []Extractor{}
+   for _, name := range names {
+      extractor, existing := EXTRACTORS[name]
+      if !existing {
+         return nil, errors.New("unknown extractor: " + name)
+      }
+      extractors = append(extractors, extractor)
+   }
+   return extractors, nil
+}
+
+/* Page creation.
+This method shall create the Page given to the extractors for the specified page URL and depth of the specified urlProcess.
+*/
+func NewPage(urlProcess *UrlProcess, pageUrl *url.URL, depth int) *Page {
+   return &Page{Url:pageUrl, Depth:depth, Process:urlProcess, openTags:map[string]int{}}
+}
+
+/* Checking open tags.
+This method shall return whether the current token of the specified receiver page is inside the specified tag.
+*/
+func (page *Page) Inside(tag string) bool {
+   return page.openTags[tag] > 0
+}
+
+// Helper function to track the open tags of the page which content matters to the extractors
+func (page *Page) trackToken(token html.Token) {
+   switch token.Data {
+      case "picture", "video", "audio", "style":
+         if token.Type == html.StartTagToken {
+            page.openTags[token.Data]++
+         } else if (token.Type == html.EndTagToken) && (page.openTags[token.Data] > 0) {
+            page.openTags[token.Data]--
+         }
+   }
+}
+
+/* Resolving a page reference.
+This method shall parse the specified reference, and return it as an absolute URL relatively to the URL of the specified receiver page.
+*/
+func (page *Page) Resolve(reference string) (*url.URL, bool) {
+   reference = strings.TrimSpace(reference)
+   if reference == "" {
+      return nil, false
+   }
+   parsedUrl, errParse := ParseUrl(&reference)
+   if errParse != nil {
+      return nil, false
+   }
+   return page.Url.ResolveReference(parsedUrl), true
+}
+
+// Helper function to get the records of the absolute URLs referenced by the specified attributes of a token
+func (page *Page) attributeRecords(token html.Token, attributes []string, detail string) []Record {
+   records := []Record{}
+   for _, attribute := range attributes {
+      if hasRef, ref := getTokenValue(token, attribute); hasRef {
+         if refAbs, resolved := page.Resolve(ref); resolved {
+            records = append(records, Record{Value:refAbs.String(), Detail:detail})
+         }
+      }
+   }
+   return records
+}
+
+func (extractor *ImagesExtractor) Name() string {
+   return IMAGES_EXTRACTOR
+}
+
+/* Extracting images.
+This method shall return the images of the specified token, of one of the image types of the job, with their srcset descriptor as detail:
+- <img> tags: src, srcset and lazy-loading data-src, data-srcset... attributes,
+- <source> tags inside a <picture> tag: srcset attributes,
+- CSS: inline style attributes, <style> tags content, and <link> stylesheets fetched as assets with their @import rules.
+*/
+func (extractor *ImagesExtractor) Extract(ctx context.Context, page *Page, token html.Token) []Record {
+   urlProcess := page.Process
+   candidates := []Record{}
+   records := []Record{}
+
+   if token.Type == html.TextToken {
+      if page.Inside("style") {
+         for _, img := range urlProcess.CssImages(ctx, token.Data, page.Url, nil) {
+            records = append(records, Record{Value:img})
+         }
+      }
+      return records
+   }
+
+   if hasStyle, style := getTokenValue(token, "style"); hasStyle {
+      for _, img := range urlProcess.CssImages(ctx, style, page.Url, nil) {
+         records = append(records, Record{Value:img})
+      }
+   }
+   switch {
+      case token.Data == "img":
+         candidates = append(candidates, page.attributeRecords(token, IMAGE_SRC_ATTRIBUTES, "")...)
+         candidates = append(candidates, page.srcsetRecords(token)...)
+      case (token.Data == "source") && page.Inside("picture"):
+         candidates = append(candidates, page.srcsetRecords(token)...)
+      case (token.Data == "link") && isStylesheetLink(getTokenAttribute(token, "rel")):
+         if sheetAbs, resolved := page.Resolve(getTokenAttribute(token, "href")); resolved {
+            for _, img := range urlProcess.StylesheetImages(ctx, sheetAbs, nil) {
+               records = append(records, Record{Value:img})
+            }
+         }
+   }
+
+   // Keeping the candidates of one of the job image types
+   for _, candidate := range candidates {
+      imgAbs, _ := url.Parse(candidate.Value)
+      if urlProcess.MatchImageType(ctx, imgAbs) {
+         records = append(records, candidate)
+      }
+   }
+   return records
+}
+
+// Helper function to get the records of the images referenced by the srcset attributes of a token, with their descriptor
+func (page *Page) srcsetRecords(token html.Token) []Record {
+   records := []Record{}
+   for _, attribute := range IMAGE_SRCSET_ATTRIBUTES {
+      if hasSrcset, srcset := getTokenValue(token, attribute); hasSrcset {
+         for _, candidate := range ParseSrcset(srcset) {
+            if imgAbs, resolved := page.Resolve(candidate.Url); resolved {
+               records = append(records, Record{Value:imgAbs.String(), Detail:candidate.Descriptor})
+            }
+         }
+      }
+   }
+   return records
+}
+
+func (extractor *ScriptsExtractor) Name() string {
+   return SCRIPTS_EXTRACTOR
+}
+
+/* Extracting scripts.
+This method shall return the scripts referenced by the src attribute of the <script> tags, with their type attribute as detail.
+*/
+func (extractor *ScriptsExtractor) Extract(ctx context.Context, page *Page, token html.Token) []Record {
+   if (token.Type == html.TextToken) || (token.Data != "script") {
+      return nil
+   }
+   return page.attributeRecords(token, []string{"src"}, getTokenAttribute(token, "type"))
+}
+
+func (extractor *StylesheetsExtractor) Name() string {
+   return STYLESHEETS_EXTRACTOR
+}
+
+/* Extracting stylesheets.
+This method shall return the stylesheets referenced by the <link rel="stylesheet"> tags, with "link" as detail, and by the @import rules
+of the <style> tags, with "import" as detail.
+*/
+func (extractor *StylesheetsExtractor) Extract(ctx context.Context, page *Page, token html.Token) []Record {
+   if token.Type == html.TextToken {
+      records := []Record{}
+      if page.Inside("style") {
+         _, cssImports := ParseCssReferences(token.Data)
+         for _, cssImport := range cssImports {
+            if importAbs, resolved := page.Resolve(cssImport); resolved {
+               records = append(records, Record{Value:importAbs.String(), Detail:"import"})
+            }
+         }
+      }
+      return records
+   }
+   if (token.Data != "link") || !isStylesheetLink(getTokenAttribute(token, "rel")) {
+      return nil
+   }
+   return page.attributeRecords(token, []string{"href"}, "link")
+}
+
+func (extractor *MediaExtractor) Name() string {
+   return MEDIA_EXTRACTOR
+}
+
+/* Extracting video and audio.
+This method shall return the media referenced by the <video> and <audio> tags (src attribute, poster attribute of the <video> tags), and by the
+<source> and <track> tags inside them, with the tag (and poster) as detail.
+*/
+func (extractor *MediaExtractor) Extract(ctx context.Context, page *Page, token html.Token) []Record {
+   if token.Type == html.TextToken {
+      return nil
+   }
+   switch {
+      case (token.Data == "video") || (token.Data == "audio"):
+         records := page.attributeRecords(token, []string{"src"}, token.Data)
+         return append(records, page.attributeRecords(token, []string{"poster"}, "poster")...)
+      case ((token.Data == "source") || (token.Data == "track")) && (page.Inside("video") || page.Inside("audio")):
+         return page.attributeRecords(token, []string{"src"}, token.Data)
+   }
+   return nil
+}
+
+func (extractor *DocumentsExtractor) Name() string {
+   return DOCUMENTS_EXTRACTOR
+}
+
+/* Extracting documents.
+This method shall return the downloadable documents referenced by the <a> tags, as per their URL path extension, with this extension as detail.
+*/
+func (extractor *DocumentsExtractor) Extract(ctx context.Context, page *Page, token html.Token) []Record {
+   if (token.Type == html.TextToken) || (token.Data != "a") {
+      return nil
+   }
+   docAbs, resolved := page.Resolve(getTokenAttribute(token, "href"))
+   if !resolved {
+      return nil
+   }
+   extension := strings.ToLower(strings.TrimPrefix(path.Ext(docAbs.Path), "."))
+   if !DOCUMENT_EXTENSIONS[extension] {
+      return nil
+   }
+   return []Record{{Value:docAbs.String(), Detail:extension}}
+}
@@ -0,0 +1,87 @@
+package UrlCrawling
+
+import (
+   "context"
+   "crypto/tls"
+   "crypto/x509"
+   "encoding/json"
+   "errors"
+   "net"
+   "net/http"
+   "strconv"
+   "strings"
+)
+
+// Error classes of the URLs which could not be crawled
+const FAILURE_INVALID_URL = "invalid_url" // URL which cannot be parsed
+const FAILURE_DNS = "dns" // Host name which cannot be resolved
+const FAILURE_TIMEOUT = "timeout"
+const FAILURE_TLS = "tls" // TLS handshake or certificate verification failure
+const FAILURE_CONNECTION = "connection" // Other network error: connection refused or reset...
+const FAILURE_HTTP = "http" // Response other than 2xx
+const FAILURE_REDIRECT = "redirect" // Redirect not followed: too many, or to another host when not allowed
+const FAILURE_TOO_LARGE = "too_large" // Response body larger than the maximum
+
+/* Failure of a URL which could not be crawled:
+- Status: HTTP status code of the response, 0 if no response,
+- Class: error class (invalid_url, dns, timeout, tls, connection, http, redirect, too_large),
+- Message: error message.*/
+type UrlFailure struct {
+   Status int `json:"status,omitempty"`
+   Class string `json:"class"`
+   Message string `json:"message"`
+}
+
+
+
+/* Failure of a request.
+This method shall return the failure of a URL which request raised the specified error, classified by its cause.
+*/
+func NewUrlFailure(err error) *UrlFailure {
+   return &UrlFailure{Class:classifyError(err), Message:err.Error()}
+}
+
+/* Failure of a response.
+This method shall return the failure of a URL which response has the specified HTTP status code, other than 2xx.
+*/
+func NewHttpFailure(statusCode int) *UrlFailure {
+   return &UrlFailure{Status:statusCode, Class:FAILURE_HTTP, Message:strconv.Itoa(statusCode) + " " + http.StatusText(statusCode)}
+}
+
+/* Decoding a failure.
+This method shall decode the specified JSON failure into the specified receiver failure, a failure recorded as a bare error message by the former
+checkpoints being accepted as a connection failure.
+*/
+func (failure *UrlFailure) UnmarshalJSON(data []byte) error {
+   var message string
+   if json.Unmarshal(data, &message) == nil {
+      *failure = UrlFailure{Class:FAILURE_CONNECTION, Message:message}
+      return nil
+   }
+   type urlFailure UrlFailure
+   return json.Unmarshal(data, (*urlFailure)(failure))
+}
+
+// Helper function to classify the error raised by a request
+func classifyError(err error) string {
+   var dnsError *net.DNSError
+   var netError net.Error
+   var unknownAuthority x509.UnknownAuthorityError
+   var hostname x509.HostnameError
+   var invalidCertificate x509.CertificateInvalidError
+   var recordHeader tls.RecordHeaderError
+   switch {
+      case errors.Is(err, errTooManyRedirects), errors.Is(err, errCrossHostRedirect):
+         return FAILURE_REDIRECT
+      case errors.Is(err, errBodyTooLarge):
+         return FAILURE_TOO_LARGE
+      case errors.As(err, &dnsError):
+         return FAILURE_DNS
+      case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError) && netError.Timeout():
+         return FAILURE_TIMEOUT
+      case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalidCertificate), errors.As(err, &recordHeader),
+         strings.Contains(err.Error(), "tls: "):
+         return FAILURE_TLS
+   }
+   return FAILURE_CONNECTION
+}
@@ -0,0 +1,203 @@
+package UrlCrawling
+
+import (
+   "container/list"
+   "context"
+   "errors"
+   "net/http"
+   "net/url"
+   "strings"
+   "sync"
+   "time"
+)
+
+const FETCH_CACHE_SWEEP_INTERVAL = time.Minute // Minimum delay between two removals of the expired pages from the fetch cache
+const FETCH_CACHE_MAX_BYTES = 256 << 20 // Maximum number of body bytes kept by the fetch cache by default
+
+/* Page fetched once and shared by all the jobs reaching it:
+- Url: final URL of the page, after redirects,
+- StatusCode: HTTP status code of the response,
+- Header: header of the response,
+- Body: content body of the page.*/
+type CachedPage struct {
+   Url *url.URL
+   StatusCode int
+   Header http.Header
+   Body []byte
+}
+
+/* Cached page: ready is closed once the page is fetched, err being set if it could not be.
+A page kept is in the recency list of the cache (element), with its cache key and its number of body bytes (size). */
+type fetchEntry struct {
+   ready chan struct{}
+   page *CachedPage
+   err error
+   expiry time.Time
+   key string
+   size int64
+   element *list.Element
+}
+
+/* Cache of the fetched pages (values) per normalized URL and request identity (keys), shared by all the jobs:
+- recency: pages kept, the most recently used first, the least recently used ones being removed once more than maxSize body bytes are kept,
+- size: number of body bytes kept,
+- swept: time of the last removal of the expired pages.*/
+var fetchCache = struct {
+   sync.Mutex
+   entries map[string]*fetchEntry
+   recency *list.List
+   size int64
+   maxSize int64
+   swept time.Time
+}{entries:map[string]*fetchEntry{}, recency:list.New(), maxSize:FETCH_CACHE_MAX_BYTES}
+
+
+
+/* URL normalization.
+This method shall return the specified URL normalized, so that the URLs of a same page share the same cache key: scheme and host in lower case,
+default port and fragment removed, empty path replaced by "/". The URL shall be returned as is if it cannot be parsed.
+*/
+func NormalizeUrl(rawUrl string) string {
+   parsedUrl, err := url.Parse(rawUrl)
+   if err != nil {
+      return rawUrl
+   }
+   parsedUrl.Scheme = strings.ToLower(parsedUrl.Scheme)
+   host := strings.ToLower(parsedUrl.Host)
+   if ((parsedUrl.Scheme == "http") && strings.HasSuffix(host, ":80")) || ((parsedUrl.Scheme == "https") && strings.HasSuffix(host, ":443")) {
+      host = host[:strings.LastIndex(host, ":")]
+   }
+   parsedUrl.Host = host
+   parsedUrl.Fragment = ""
+   parsedUrl.RawFragment = ""
+   if parsedUrl.Path == "" {
+      parsedUrl.Path = "/"
+   }
+   return parsedUrl.String()
+}
+
+// Helper function to remove the expired pages from the fetch cache, at most once per sweep interval; called with the cache locked
+func sweepFetchCache(now time.Time) {
+   if now.Sub(fetchCache.swept) < FETCH_CACHE_SWEEP_INTERVAL {
+      return
+   }
+   fetchCache.swept = now
+   for _, entry := range fetchCache.entries {
+      select {
+         case <-entry.ready:
+            if now.After(entry.expiry) {
+               removeFetchEntry(entry)
+            }
+         default:
+      }
+   }
+}
+
+// Helper function to remove the specified entry from the fetch cache; called with the cache locked
+func removeFetchEntry(entry *fetchEntry) {
+   if fetchCache.entries[entry.key] == entry {
+      delete(fetchCache.entries, entry.key)
+   }
+   if entry.element != nil {
+      fetchCache.recency.Remove(entry.element)
+      fetchCache.size -= entry.size
+      entry.element = nil
+   }
+}
+
+// Helper function to keep the page of the specified entry in the fetch cache, removing the least recently used pages beyond the maximum size, the
+// page itself not being kept if larger than the maximum size; called with the cache locked
+func keepFetchEntry(entry *fetchEntry) {
+   entry.size = int64(len(entry.page.Body))
+   if (fetchCache.entries[entry.key] != entry) || (entry.size > fetchCache.maxSize) {
+      removeFetchEntry(entry)
+      entry.expiry = time.Now()
+      return
+   }
+   entry.element = fetchCache.recency.PushFront(entry)
+   fetchCache.size += entry.size
+   for (fetchCache.size > fetchCache.maxSize) && (fetchCache.recency.Len() > 0) {
+      removeFetchEntry(fetchCache.recency.Back().Value.(*fetchEntry))
+   }
+}
+
+/* Setting the fetch cache maximum size.
+This method shall set the maximum number of body bytes kept by the fetch cache, FETCH_CACHE_MAX_BYTES by default, removing the least recently used
+pages beyond it.
+*/
+func SetFetchCacheMaxSize(maxSize int64) {
+   fetchCache.Lock()
+   defer fetchCache.Unlock()
+   fetchCache.maxSize = maxSize
+   for (fetchCache.size > fetchCache.maxSize) && (fetchCache.recency.Len() > 0) {
+      removeFetchEntry(fetchCache.recency.Back().Value.(*fetchEntry))
+   }
+}
+
+/* Getting a cached page.
+This method shall return the page of the specified URL from the shared fetch cache, fetching it with the specified fetch function only if not cached
+yet or expired. The pages fetched with different request identities (user agent, headers, cookies) shall be cached apart, the specified identity
+being empty for the default one. Concurrent callers for the same normalized URL and identity shall wait for a single fetch.
+A page successfully fetched (2xx) shall be kept for the specified TTL, unless the least recently used pages are removed to keep the cache within its
+maximum size (see SetFetchCacheMaxSize); a page in error shall not be kept. A caller which fetch was aborted by its context
+shall not fail the other callers, which shall fetch the page again.
+*/
+func GetCachedPage(ctx context.Context, pageUrl string, identity string, ttl time.Duration, fetch func() (*CachedPage, error)) (*CachedPage, error) {
+   key := NormalizeUrl(pageUrl)
+   if identity != "" {
+      key += " " + identity
+   }
+   for {
+      fetchCache.Lock()
+      now := time.Now()
+      sweepFetchCache(now)
+      entry, existing := fetchCache.entries[key]
+      if existing {
+         select {
+            case <-entry.ready:
+               // Already fetched: fetching again if expired, marking it as recently used else
+               existing = !now.After(entry.expiry)
+               if !existing {
+                  removeFetchEntry(entry)
+               } else if entry.element != nil {
+                  fetchCache.recency.MoveToFront(entry.element)
+               }
+            default:
+         }
+      }
+      if !existing {
+         entry = &fetchEntry{ready:make(chan struct{}), key:key}
+         fetchCache.entries[key] = entry
+         fetchCache.Unlock()
+
+         entry.page, entry.err = fetch()
+         fetchCache.Lock()
+         entry.expiry = time.Now().Add(ttl)
+         if (entry.err != nil) || (entry.page.StatusCode < 200) || (entry.page.StatusCode >= 300) || (ctx.Err() != nil) {
+            // Not keeping the page for the other jobs
+            removeFetchEntry(entry)
+            entry.expiry = time.Now()
+            if (entry.err == nil) && (ctx.Err() != nil) {
+               entry.err = ctx.Err()
+            }
+         } else {
+            keepFetchEntry(entry)
+         }
+         fetchCache.Unlock()
+         close(entry.ready)
+         return entry.page, entry.err
+      }
+      fetchCache.Unlock()
+
+      select {
+         case <-entry.ready:
+            if (entry.err != nil) && errors.Is(entry.err, context.Canceled) && (ctx.Err() == nil) {
+               // Fetch aborted by another job: fetching again
+               continue
+            }
+            return entry.page, entry.err
+         case <-ctx.Done():
+            return nil, ctx.Err()
+      }
+   }
+}
@@ -0,0 +1,161 @@
+package UrlCrawling
+
+import (
+   "container/list"
+   "context"
+   "errors"
+   "net/http"
+   "strings"
+   "testing"
+   "time"
+)
+
+// Helper function to empty the fetch cache for a test, with the specified maximum size, and restore it at the end of the test
+func resetFetchCache(t *testing.T, maxSize int64) {
+   fetchCache.Lock()
+   entries, recency, size, previousMaxSize, swept := fetchCache.entries, fetchCache.recency, fetchCache.size, fetchCache.maxSize, fetchCache.swept
+   fetchCache.entries, fetchCache.recency, fetchCache.size, fetchCache.maxSize, fetchCache.swept = map[string]*fetchEntry{}, list.New(), 0, maxSize,
+      time.Now()
+   fetchCache.Unlock()
+   t.Cleanup(func() {
+      fetchCache.Lock()
+      fetchCache.entries, fetchCache.recency, fetchCache.size, fetchCache.maxSize, fetchCache.swept = entries, recency, size, previousMaxSize, swept
+      fetchCache.Unlock()
+   })
+}
+
+// Helper function to get a page from the fetch cache, fetched with the specified status code and body, and return whether it was fetched
+func getTestPage(t *testing.T, pageUrl string, ttl time.Duration, statusCode int, body string) bool {
+   fetched := false
+   page, err := GetCachedPage(context.Background(), pageUrl, "", ttl, func() (*CachedPage, error) {
+      fetched = true
+      return &CachedPage{StatusCode:statusCode, Header:http.Header{}, Body:[]byte(body)}, nil
+   })
+   if (err != nil) || (string(page.Body) != body) {
+      t.Fatalf("GetCachedPage(%q) = %v, %v", pageUrl, page, err)
+   }
+   return fetched
+}
+
+func TestNormalizeUrl(t *testing.T) {
+   tests := []struct {
+      url string
+      want string
+   }{
+      {"HTTP://Example.COM", "http://example.com/"},
+      {"http://example.com:80/page#top", "http://example.com/page"},
+      {"https://example.com:443/page?q=1", "https://example.com/page?q=1"},
+      {"https://example.com:8443/", "https://example.com:8443/"},
+      {"http://example.com/Page", "http://example.com/Page"},
+      {"%zz", "%zz"},
+   }
+   for _, test := range tests {
+      if got := NormalizeUrl(test.url); got != test.want {
+         t.Errorf("NormalizeUrl(%q) = %q, want %q", test.url, got, test.want)
+      }
+   }
+}
+
+func TestGetCachedPageTTL(t *testing.T) {
+   resetFetchCache(t, FETCH_CACHE_MAX_BYTES)
+   ttl := 100 * time.Millisecond
+   tests := []struct {
+      url string
+      wait time.Duration
+      statusCode int
+      fetched bool
+   }{
+      {"http://example.com/", 0, http.StatusOK, true},
+      {"http://EXAMPLE.com:80/#fragment", 0, http.StatusOK, false},
+      {"http://example.com/", 2 * ttl, http.StatusOK, true},
+      {"http://example.com/", 0, http.StatusOK, false},
+      // Pages in error not kept
+      {"http://example.com/error", 0, http.StatusServiceUnavailable, true},
+      {"http://example.com/error", 0, http.StatusServiceUnavailable, true},
+   }
+   for i, test := range tests {
+      time.Sleep(test.wait)
+      if fetched := getTestPage(t, test.url, ttl, test.statusCode, "page"); fetched != test.fetched {
+         t.Errorf("request %d of %s: fetched %v, want %v", i, test.url, fetched, test.fetched)
+      }
+   }
+
+   // Fetch error not kept
+   fetchErr := errors.New("connection refused")
+   for i := 0; i < 2; i++ {
+      fetches := 0
+      _, err := GetCachedPage(context.Background(), "http://example.com/down", "", ttl, func() (*CachedPage, error) {
+         fetches++
+         return nil, fetchErr
+      })
+      if (err != fetchErr) || (fetches != 1) {
+         t.Errorf("request %d of a page failing: error %v after %d fetches, want %v after 1", i, err, fetches, fetchErr)
+      }
+   }
+
+   // Pages fetched with another identity cached apart
+   fetched := false
+   GetCachedPage(context.Background(), "http://example.com/", "identity", ttl, func() (*CachedPage, error) {
+      fetched = true
+      return &CachedPage{StatusCode:http.StatusOK, Body:[]byte("page")}, nil
+   })
+   if !fetched {
+      t.Error("page cached for the default identity returned for another one")
+   }
+}
+
+func TestGetCachedPageSweep(t *testing.T) {
+   resetFetchCache(t, FETCH_CACHE_MAX_BYTES)
+   getTestPage(t, "http://example.com/expired", time.Millisecond, http.StatusOK, "expired")
+   getTestPage(t, "http://example.com/kept", time.Hour, http.StatusOK, "kept")
+   time.Sleep(10 * time.Millisecond)
+
+   // Expired page removed by the sweep on the next lookup, whatever its URL, once the sweep interval elapsed
+   fetchCache.Lock()
+   fetchCache.swept = time.Now().Add(-FETCH_CACHE_SWEEP_INTERVAL)
+   fetchCache.Unlock()
+   getTestPage(t, "http://example.com/kept", time.Hour, http.StatusOK, "kept")
+
+   fetchCache.Lock()
+   defer fetchCache.Unlock()
+   if _, existing := fetchCache.entries[NormalizeUrl("http://example.com/expired")]; existing {
+      t.Error("expired page not swept")
+   }
+   if (len(fetchCache.entries) != 1) || (fetchCache.recency.Len() != 1) || (fetchCache.size != int64(len("kept"))) {
+      t.Errorf("cache of %d entries, %d in the recency list, %d bytes, want the kept page only", len(fetchCache.entries), fetchCache.recency.Len(),
+         fetchCache.size)
+   }
+}
+
+func TestGetCachedPageMaxSize(t *testing.T) {
+   resetFetchCache(t, 10)
+   body := strings.Repeat("x", 4)
+   tests := []struct {
+      url string
+      body string
+      fetched bool
+   }{
+      {"http://example.com/a", body, true},
+      {"http://example.com/b", body, true},
+      // a used more recently than b
+      {"http://example.com/a", body, false},
+      // c exceeding the maximum size: b removed
+      {"http://example.com/c", body, true},
+      {"http://example.com/a", body, false},
+      {"http://example.com/b", body, true},
+      // Page larger than the maximum size never kept, the other pages kept
+      {"http://example.com/large", strings.Repeat("x", 11), true},
+      {"http://example.com/large", strings.Repeat("x", 11), true},
+      {"http://example.com/b", body, false},
+   }
+   for i, test := range tests {
+      if fetched := getTestPage(t, test.url, time.Hour, http.StatusOK, test.body); fetched != test.fetched {
+         t.Errorf("request %d of %s: fetched %v, want %v", i, test.url, fetched, test.fetched)
+      }
+      fetchCache.Lock()
+      if fetchCache.size > fetchCache.maxSize {
+         t.Errorf("request %d of %s: %d bytes kept, more than %d", i, test.url, fetchCache.size, fetchCache.maxSize)
+      }
+      fetchCache.Unlock()
+   }
+}
@@ -0,0 +1,241 @@
+package UrlCrawling
+
+import (
+   "container/heap"
+   "errors"
+   "sync"
+)
+
+const FRONTIER_BFS = "bfs"
+const FRONTIER_DFS = "dfs"
+const FRONTIER_BEST_FIRST = "best_first"
+const FRONTIER_ROUND_ROBIN = "round_robin"
+
+/* Frontier strategy used by default when a job does not specify any */
+const DEFAULT_FRONTIER = FRONTIER_BFS
+
+/* Frontier of a job, deciding the order in which the waiting URLs of all its Job URLs are crawled.
+Push shall add the specified URL with its info, waiting to be crawled for the specified Job URL (seed), replacing its previous info if already pushed;
+Pop shall remove and return the next one, or false if none. The entries which info is not the current one in the waiting URLs set anymore are stale,
+and shall be skipped by the caller.
+The frontiers shall be safe for concurrent use. */
+type Frontier interface {
+   Push(seed string, url string, info *UrlInfo)
+   Pop() (seed string, url string, info *UrlInfo, available bool)
+}
+
+/* Scoring function of the URLs for the best-first frontier, the URLs with the highest score being crawled first */
+type UrlScorer func(url string, info *UrlInfo) float64
+
+/* Entry of a frontier: URL waiting to be crawled for a seed, with its info, its depth and score when pushed, its sequence number when first pushed,
+and its position in the heap */
+type frontierEntry struct {
+   seed string
+   url string
+   info *UrlInfo
+   depth int
+   score float64
+   seq int
+   index int
+}
+
+/* Entries of a heap frontier, ordered according to the less function (entry a to be crawled before entry b) */
+type frontierHeap struct {
+   entries []*frontierEntry
+   less func(a *frontierEntry, b *frontierEntry) bool
+}
+
+/* Frontier keeping its entries in a heap:
+- entries: entries waiting to be crawled,
+- pushed: entries waiting to be crawled, by seed and URL,
+- score: scoring function of the pushed URLs, if any,
+- seq: sequence number of the next pushed URL.*/
+type heapFrontier struct {
+   sync.Mutex
+   entries frontierHeap
+   pushed map[string]*frontierEntry
+   score UrlScorer
+   seq int
+}
+
+/* Frontier serving its seeds in turn, each seed being crawled breadth-first:
+- seeds: seeds in the order of their first push,
+- queues: breadth-first frontier of each seed,
+- next: position in seeds of the next seed to serve.*/
+type roundRobinFrontier struct {
+   sync.Mutex
+   seeds []string
+   queues map[string]*heapFrontier
+   next int
+}
+
+
+
+/* Frontier creation.
+This method shall create the frontier matching the specified strategy name, the default one if empty, or return an error for an unknown strategy:
+- bfs: breadth-first, the shallowest URLs first, in their push order,
+- dfs: depth-first, the deepest URLs first, the last pushed first,
+- best_first: the URLs with the highest ScoreUrl score first, in their push order,
+- round_robin: one URL of each seed in turn, each seed being crawled breadth-first.
+*/
+func NewFrontier(name string) (Frontier, error) {
+   switch name {
+      case "", FRONTIER_BFS:
+         return newHeapFrontier(breadthFirst, nil), nil
+      case FRONTIER_DFS:
+         return newHeapFrontier(depthFirst, nil), nil
+      case FRONTIER_BEST_FIRST:
+         return NewBestFirstFrontier(ScoreUrl), nil
+      case FRONTIER_ROUND_ROBIN:
+         return &roundRobinFrontier{queues:map[string]*heapFrontier{}}, nil
+   }
+   return nil, errors.New("unknown frontier: " + name)
+}
+
+/* Best-first frontier creation.
+This method shall create a frontier returning first the URLs with the highest score according to the specified scoring function,
+in their push order for a same score.
+*/
+func NewBestFirstFrontier(score UrlScorer) Frontier {
+   return newHeapFrontier(bestFirst, score)
+}
+
+/* Default URL scoring.
+This method shall score the specified URL with its sitemap priority (the default sitemap priority for the URLs not found in sitemaps),
+divided by one plus its depth, so that the shallow URLs given a high priority by the site are crawled first.
+*/
+func ScoreUrl(url string, info *UrlInfo) float64 {
+   priority := info.Priority
+   if priority <= 0 {
+      priority = DEFAULT_SITEMAP_PRIORITY
+   }
+   return priority / float64(1 + info.Depth)
+}
+
+// Helper functions ordering the entries of the heap frontiers
+func breadthFirst(a *frontierEntry, b *frontierEntry) bool {
+   if a.depth != b.depth {
+      return a.depth < b.depth
+   }
+   return a.seq < b.seq
+}
+
+func depthFirst(a *frontierEntry, b *frontierEntry) bool {
+   if a.depth != b.depth {
+      return a.depth > b.depth
+   }
+   return a.seq > b.seq
+}
+
+func bestFirst(a *frontierEntry, b *frontierEntry) bool {
+   if a.score != b.score {
+      return a.score > b.score
+   }
+   return a.seq < b.seq
+}
+
+// Helper function to create a heap frontier
+func newHeapFrontier(less func(a *frontierEntry, b *frontierEntry) bool, score UrlScorer) *heapFrontier {
+   return &heapFrontier{entries:frontierHeap{less:less}, pushed:map[string]*frontierEntry{}, score:score}
+}
+
+// Implementation of heap.Interface by the entries of the heap frontiers
+func (entries *frontierHeap) Len() int {
+   return len(entries.entries)
+}
+
+func (entries *frontierHeap) Less(i int, j int) bool {
+   return entries.less(entries.entries[i], entries.entries[j])
+}
+
+func (entries *frontierHeap) Swap(i int, j int) {
+   entries.entries[i], entries.entries[j] = entries.entries[j], entries.entries[i]
+   entries.entries[i].index = i
+   entries.entries[j].index = j
+}
+
+func (entries *frontierHeap) Push(entry interface{}) {
+   entry.(*frontierEntry).index = len(entries.entries)
+   entries.entries = append(entries.entries, entry.(*frontierEntry))
+}
+
+func (entries *frontierHeap) Pop() interface{} {
+   last := len(entries.entries) - 1
+   entry := entries.entries[last]
+   entries.entries[last] = nil
+   entries.entries = entries.entries[:last]
+   return entry
+}
+
+/* Heap frontier push.
+This method shall add the specified URL, with its info, waiting to be crawled for the specified seed in the specified receiver frontier.
+A URL already waiting for the seed shall keep its place in the push order, and be moved according to its new info.
+*/
+func (frontier *heapFrontier) Push(seed string, url string, info *UrlInfo) {
+   frontier.Lock()
+   defer frontier.Unlock()
+   key := seed + " " + url
+   entry, existing := frontier.pushed[key]
+   if !existing {
+      entry = &frontierEntry{seed:seed, url:url, seq:frontier.seq}
+      frontier.seq++
+   }
+   entry.info = info
+   entry.depth = info.Depth
+   if frontier.score != nil {
+      entry.score = frontier.score(url, info)
+   }
+   if existing {
+      heap.Fix(&frontier.entries, entry.index)
+   } else {
+      frontier.pushed[key] = entry
+      heap.Push(&frontier.entries, entry)
+   }
+}
+
+/* Heap frontier pop.
+This method shall remove and return the next URL to crawl, with its seed and info, from the specified receiver frontier, or false if empty.
+*/
+func (frontier *heapFrontier) Pop() (string, string, *UrlInfo, bool) {
+   frontier.Lock()
+   defer frontier.Unlock()
+   if frontier.entries.Len() == 0 {
+      return "", "", nil, false
+   }
+   entry := heap.Pop(&frontier.entries).(*frontierEntry)
+   delete(frontier.pushed, entry.seed + " " + entry.url)
+   return entry.seed, entry.url, entry.info, true
+}
+
+/* Round-robin frontier push.
+This method shall add the specified URL, with its info, to the breadth-first queue of the specified seed in the specified receiver frontier.
+*/
+func (frontier *roundRobinFrontier) Push(seed string, url string, info *UrlInfo) {
+   frontier.Lock()
+   queue, existing := frontier.queues[seed]
+   if !existing {
+      queue = newHeapFrontier(breadthFirst, nil)
+      frontier.queues[seed] = queue
+      frontier.seeds = append(frontier.seeds, seed)
+   }
+   frontier.Unlock()
+   queue.Push(seed, url, info)
+}
+
+/* Round-robin frontier pop.
+This method shall remove and return the next URL to crawl from the queue of the seed following the last served one in the specified receiver
+frontier, the seeds with an empty queue being skipped, or false if all the queues are empty.
+*/
+func (frontier *roundRobinFrontier) Pop() (string, string, *UrlInfo, bool) {
+   frontier.Lock()
+   defer frontier.Unlock()
+   nbSeeds := len(frontier.seeds)
+   for i := 0; i < nbSeeds; i++ {
+      position := (frontier.next + i) % nbSeeds
+      if seed, url, info, available := frontier.queues[frontier.seeds[position]].Pop(); available {
+         frontier.next = (position + 1) % nbSeeds
+         return seed, url, info, true
+      }
+   }
+   return "", "", nil, false
+}
@@ -0,0 +1,137 @@
+package UrlCrawling
+
+import (
+   "reflect"
+   "testing"
+   "time"
+)
+
+/* URL pushed in a frontier in a test, with its seed and info */
+type frontierPush struct {
+   seed string
+   url string
+   info *UrlInfo
+}
+
+// Helper function to pop all the URLs of the specified frontier, in their pop order
+func popAll(frontier Frontier) []string {
+   urls := []string{}
+   for {
+      _, url, _, available := frontier.Pop()
+      if !available {
+         return urls
+      }
+      urls = append(urls, url)
+   }
+}
+
+func TestFrontierOrder(t *testing.T) {
+   pushes := []frontierPush{
+      {"s1", "a", &UrlInfo{Depth:0}},
+      {"s1", "b", &UrlInfo{Depth:1}},
+      {"s1", "c", &UrlInfo{Depth:2, Priority:1}},
+      {"s2", "d", &UrlInfo{Depth:0}},
+      {"s2", "e", &UrlInfo{Depth:1, Priority:1}},
+      {"s1", "f", &UrlInfo{Depth:1}},
+      {"s2", "g", &UrlInfo{Depth:2}},
+   }
+   tests := []struct {
+      name string
+      want []string
+   }{
+      {FRONTIER_BFS, []string{"a", "d", "b", "e", "f", "c", "g"}},
+      {"", []string{"a", "d", "b", "e", "f", "c", "g"}},
+      {FRONTIER_DFS, []string{"g", "c", "f", "e", "b", "d", "a"}},
+      // Scores: a 0.5, b 0.25, c 0.33, d 0.5, e 0.5, f 0.25, g 0.17
+      {FRONTIER_BEST_FIRST, []string{"a", "d", "e", "c", "b", "f", "g"}},
+      {FRONTIER_ROUND_ROBIN, []string{"a", "d", "b", "e", "f", "g", "c"}},
+   }
+   for _, test := range tests {
+      frontier, err := NewFrontier(test.name)
+      if err != nil {
+         t.Errorf("NewFrontier(%q): unexpected error %v", test.name, err)
+         continue
+      }
+      for _, push := range pushes {
+         frontier.Push(push.seed, push.url, push.info)
+      }
+      if got := popAll(frontier); !reflect.DeepEqual(got, test.want) {
+         t.Errorf("%q frontier order %v, want %v", test.name, got, test.want)
+      }
+   }
+
+   if _, err := NewFrontier("random"); err == nil {
+      t.Error("NewFrontier(\"random\"): error expected")
+   }
+}
+
+func TestFrontierRepush(t *testing.T) {
+   tests := []struct {
+      name string
+      want []string
+   }{
+      // b moved to depth 0 keeps its first push order: before c, after a
+      {FRONTIER_BFS, []string{"a", "b", "c"}},
+      {FRONTIER_DFS, []string{"c", "b", "a"}},
+      // b given the highest score
+      {FRONTIER_BEST_FIRST, []string{"b", "a", "c"}},
+   }
+   for _, test := range tests {
+      frontier, _ := NewFrontier(test.name)
+      frontier.Push("s", "a", &UrlInfo{Depth:0})
+      frontier.Push("s", "b", &UrlInfo{Depth:2})
+      frontier.Push("s", "c", &UrlInfo{Depth:0})
+      repushed := &UrlInfo{Depth:0, Priority:1}
+      frontier.Push("s", "b", repushed)
+
+      got := []string{}
+      for {
+         _, url, info, available := frontier.Pop()
+         if !available {
+            break
+         }
+         if (url == "b") && (info != repushed) {
+            t.Errorf("%q frontier: b popped with its former info", test.name)
+         }
+         got = append(got, url)
+      }
+      if !reflect.DeepEqual(got, test.want) {
+         t.Errorf("%q frontier order after push again %v, want %v", test.name, got, test.want)
+      }
+   }
+}
+
+func TestFrontierStaleEntries(t *testing.T) {
+   seed := "http://example.com/"
+   frontier, _ := NewFrontier(FRONTIER_BFS)
+   urlProcess := &UrlProcess{}
+   urlProcess.InitUrlProcess(&seed, &CrawlConfig{}, frontier)
+
+   // Page waiting to be retried later: its entry in the frontier being stale until then
+   page := "http://example.com/page"
+   urlProcess.WaitingUrls.Lock()
+   urlProcess.PushWaitingUrl(page, &UrlInfo{Depth:1})
+   notBefore := time.Now().Add(time.Hour)
+   urlProcess.PushWaitingUrl(page, &UrlInfo{Depth:1, Attempts:1, NotBefore:&notBefore})
+   urlProcess.WaitingUrls.Unlock()
+
+   taken := []string{}
+   for {
+      _, url, info, available := frontier.Pop()
+      if !available {
+         break
+      }
+      if urlProcess.TakeWaitingUrl(url, info) {
+         taken = append(taken, url)
+      }
+   }
+   if !reflect.DeepEqual(taken, []string{seed}) {
+      t.Errorf("URLs taken %v, want %v", taken, []string{seed})
+   }
+   urlProcess.WaitingUrls.Lock()
+   info, waiting := urlProcess.WaitingUrls.Urls[page]
+   urlProcess.WaitingUrls.Unlock()
+   if !waiting || (info.Attempts != 1) {
+      t.Error("URL waiting to be retried removed by its stale frontier entry")
+   }
+}
@@ -0,0 +1,135 @@
+package UrlCrawling
+
+import (
+   "net/http"
+   "sort"
+   "sync"
+   "time"
+)
+
+const HISTORY_MAX_AGE = 30 * 24 * time.Hour // Maximum age of a page kept for the later crawls without being crawled again
+const HISTORY_MAX_PAGES = 100000 // Maximum number of pages kept for the later crawls of a seed set
+
+/* Crawled page, as kept for the later crawls of the same seed set:
+- ETag, LastModified: validators of the page response, sent back in the conditional requests,
+- Links: absolute URLs of the links found in the page, followed again if the page is not modified,
+- Data: data extracted from the page, reused if the page is not modified,
+- RecordedAt: time of the last crawl of the page, zero for the pages recorded before it was kept.*/
+type PageRecord struct {
+   ETag string `json:"etag,omitempty"`
+   LastModified string `json:"last_modified,omitempty"`
+   Links []string `json:"links,omitempty"`
+   Data PageData `json:"data,omitempty"`
+   RecordedAt time.Time `json:"recorded_at,omitempty"`
+}
+
+/* History of the pages crawled for a seed set, shared by the processing info of all the URLs of a job:
+- previous: pages (values) per URL (keys) recorded by the last crawl, read only,
+- current: pages (values) per URL (keys) recorded by the ongoing crawl.*/
+type CrawlHistory struct {
+   sync.Mutex
+   previous map[string]*PageRecord
+   current map[string]*PageRecord
+}
+
+
+
+/* Crawl history creation.
+This method shall create the history of an ongoing crawl, from the specified pages recorded by the last crawl of the same seed set (none if nil).
+*/
+func NewCrawlHistory(previous map[string]*PageRecord) *CrawlHistory {
+   if previous == nil {
+      previous = map[string]*PageRecord{}
+   }
+   return &CrawlHistory{previous:previous, current:map[string]*PageRecord{}}
+}
+
+/* Getting a previous page.
+This method shall return the page of the specified URL recorded by the last crawl in the specified receiver history, nil if none or without
+validators, so that a conditional request can be sent for it. It shall return nil for a nil history.
+*/
+func (history *CrawlHistory) Previous(pageUrl string) *PageRecord {
+   if history == nil {
+      return nil
+   }
+   history.Lock()
+   defer history.Unlock()
+   page := history.previous[pageUrl]
+   if (page == nil) || ((page.ETag == "") && (page.LastModified == "")) {
+      return nil
+   }
+   return page
+}
+
+/* Recording a page.
+This method shall record in the specified receiver history a copy of the specified page crawled at the specified URL by the ongoing crawl, with the
+current time. Nothing shall be recorded for a nil history.
+*/
+func (history *CrawlHistory) Record(pageUrl string, page *PageRecord) {
+   if history == nil {
+      return
+   }
+   record := *page
+   record.RecordedAt = time.Now()
+   history.Lock()
+   history.current[pageUrl] = &record
+   history.Unlock()
+}
+
+/* Getting the recorded pages.
+This method shall return the pages recorded by the ongoing crawl in the specified receiver history, completed by the pages of the last crawl not
+crawled again, so that they are kept for the next crawl. The pages of the last crawl shall only be kept if crawled less than HISTORY_MAX_AGE ago,
+the most recently crawled first, up to HISTORY_MAX_PAGES pages in all; the pages recorded without time being kept as crawled now.
+*/
+func (history *CrawlHistory) Records() map[string]*PageRecord {
+   history.Lock()
+   defer history.Unlock()
+   now := time.Now()
+   records := make(map[string]*PageRecord, len(history.current))
+   for pageUrl, page := range history.current {
+      records[pageUrl] = page
+   }
+
+   // Pages of the last crawl not crawled again, and not too old
+   kept := []string{}
+   for pageUrl, page := range history.previous {
+      if _, crawled := records[pageUrl]; crawled {
+         continue
+      }
+      if page.RecordedAt.IsZero() {
+         record := *page
+         record.RecordedAt = now
+         page = &record
+      }
+      if now.Sub(page.RecordedAt) <= HISTORY_MAX_AGE {
+         records[pageUrl] = page
+         kept = append(kept, pageUrl)
+      }
+   }
+
+   // Too many pages: removing the least recently crawled pages of the last crawl
+   if len(records) > HISTORY_MAX_PAGES {
+      sort.Slice(kept, func(i int, j int) bool { return records[kept[i]].RecordedAt.After(records[kept[j]].RecordedAt) })
+      keptPages := HISTORY_MAX_PAGES - len(history.current)
+      if keptPages < 0 {
+         keptPages = 0
+      }
+      for _, pageUrl := range kept[keptPages:] {
+         delete(records, pageUrl)
+      }
+   }
+   return records
+}
+
+// Helper function to add the validators of the specified previous page to a request, making it conditional
+func setValidators(request *http.Request, previous *PageRecord) {
+   if previous == nil {
+      return
+   }
+   if previous.ETag != "" {
+      request.Header.Set("If-None-Match", previous.ETag)
+   }
+   if previous.LastModified != "" {
+      request.Header.Set("If-Modified-Since", previous.LastModified)
+   }
+}
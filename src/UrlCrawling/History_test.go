@@ -0,0 +1,152 @@
+package UrlCrawling
+
+import (
+   "context"
+   "net/http"
+   "net/http/httptest"
+   "reflect"
+   "strconv"
+   "sync"
+   "testing"
+   "time"
+)
+
+func TestCrawlUrlConditionalRequest(t *testing.T) {
+   // Page of ETag "v1", last modified at lastModified, answering 304 to the conditional requests matching them
+   lastModified := "Mon, 02 Jan 2006 15:04:05 GMT"
+   var lock sync.Mutex
+   received := http.Header{}
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      if r.URL.Path != "/" {
+         http.NotFound(w, r)
+         return
+      }
+      lock.Lock()
+      received = r.Header.Clone()
+      lock.Unlock()
+      if (r.Header.Get("If-None-Match") == `"v1"`) || (r.Header.Get("If-Modified-Since") == lastModified) {
+         w.WriteHeader(http.StatusNotModified)
+         return
+      }
+      w.Header().Set("ETag", `"v1"`)
+      w.Header().Set("Last-Modified", lastModified)
+      w.Write([]byte(`<html><body><img src="/new.png"></body></html>`))
+   }))
+   t.Cleanup(server.Close)
+   seed := server.URL + "/"
+   previousData := PageData{IMAGES_EXTRACTOR:{server.URL + "/old.png":""}}
+
+   tests := []struct {
+      name string
+      previous *PageRecord
+      ifNoneMatch string
+      ifModifiedSince string
+      unchanged bool
+      image string
+   }{
+      {"no previous page", nil, "", "", false, server.URL + "/new.png"},
+      {"previous page without validators", &PageRecord{Data:previousData}, "", "", false, server.URL + "/new.png"},
+      {"same ETag", &PageRecord{ETag:`"v1"`, Data:previousData}, `"v1"`, "", true, server.URL + "/old.png"},
+      {"same last modification", &PageRecord{LastModified:lastModified, Data:previousData}, "", lastModified, true, server.URL + "/old.png"},
+      {"other ETag", &PageRecord{ETag:`"v0"`, Data:previousData}, `"v0"`, "", false, server.URL + "/new.png"},
+   }
+   for _, test := range tests {
+      previous := map[string]*PageRecord{}
+      if test.previous != nil {
+         previous[seed] = test.previous
+      }
+      config := &CrawlConfig{ImageTypes:NewImageTypes([]string{"png"}), History:NewCrawlHistory(previous), HostMaxConcurrent:1}
+      config.Extractors, _ = NewExtractors(nil)
+      frontier, _ := NewFrontier("")
+      urlProcess := &UrlProcess{}
+      urlProcess.InitUrlProcess(&seed, config, frontier)
+      urlProcess.TakeWaitingUrl(seed, urlProcess.WaitingUrls.Urls[seed])
+      urlProcess.CrawlUrl(context.Background(), &seed, 0)
+
+      lock.Lock()
+      ifNoneMatch, ifModifiedSince := received.Get("If-None-Match"), received.Get("If-Modified-Since")
+      lock.Unlock()
+      if (ifNoneMatch != test.ifNoneMatch) || (ifModifiedSince != test.ifModifiedSince) {
+         t.Errorf("%s: request with If-None-Match %q, If-Modified-Since %q, want %q, %q", test.name, ifNoneMatch, ifModifiedSince, test.ifNoneMatch,
+            test.ifModifiedSince)
+      }
+      if status := urlProcess.HttpStatuses()[seed]; (status == http.StatusNotModified) != test.unchanged {
+         t.Errorf("%s: HTTP status %d", test.name, status)
+      }
+      if urlProcess.CountUnchanged() != map[bool]int{false:0, true:1}[test.unchanged] {
+         t.Errorf("%s: unchanged URLs %v, want unchanged %v", test.name, urlProcess.Unchanged, test.unchanged)
+      }
+      if _, failed := urlProcess.Failure(seed); failed {
+         t.Errorf("%s: page failed", test.name)
+      }
+      urlProcess.ProcessingUrls.Lock()
+      images := urlProcess.ProcessingUrls.UrlsData[seed][IMAGES_EXTRACTOR]
+      urlProcess.ProcessingUrls.Unlock()
+      if _, existing := images[test.image]; !existing || (len(images) != 1) {
+         t.Errorf("%s: images %v, want %s", test.name, images, test.image)
+      }
+
+      // Page recorded for the next crawl with the time of the crawl, without changing the previous page
+      record := config.History.Records()[seed]
+      if (record == nil) || record.RecordedAt.IsZero() || (time.Since(record.RecordedAt) > time.Minute) {
+         t.Errorf("%s: page recorded as %+v", test.name, record)
+      }
+      if (test.previous != nil) && !test.previous.RecordedAt.IsZero() {
+         t.Errorf("%s: previous page modified", test.name)
+      }
+   }
+}
+
+func TestCrawlHistoryRecords(t *testing.T) {
+   now := time.Now()
+   old := now.Add(-HISTORY_MAX_AGE - time.Hour)
+   history := NewCrawlHistory(map[string]*PageRecord{
+      "recrawled": {ETag:"previous", RecordedAt:old},
+      "recent": {ETag:"recent", RecordedAt:now.Add(-time.Hour)},
+      "old": {ETag:"old", RecordedAt:old},
+      "legacy": {ETag:"legacy"},
+   })
+   history.Record("recrawled", &PageRecord{ETag:"current"})
+   history.Record("new", &PageRecord{ETag:"new"})
+
+   records := history.Records()
+   etags := map[string]string{}
+   for pageUrl, page := range records {
+      etags[pageUrl] = page.ETag
+      if page.RecordedAt.IsZero() {
+         t.Errorf("page %s recorded without time", pageUrl)
+      }
+   }
+   want := map[string]string{"recrawled":"current", "new":"new", "recent":"recent", "legacy":"legacy"}
+   if !reflect.DeepEqual(etags, want) {
+      t.Errorf("Records() = %v, want %v", etags, want)
+   }
+}
+
+func TestCrawlHistoryRecordsMaxPages(t *testing.T) {
+   // Pages of the last crawl, the higher their number the more recent, along a few pages crawled again
+   now := time.Now()
+   previous := map[string]*PageRecord{}
+   for i := 0; i < HISTORY_MAX_PAGES; i++ {
+      previous["previous" + strconv.Itoa(i)] = &PageRecord{RecordedAt:now.Add(time.Duration(i - HISTORY_MAX_PAGES) * time.Second)}
+   }
+   history := NewCrawlHistory(previous)
+   for i := 0; i < 10; i++ {
+      history.Record("current" + strconv.Itoa(i), &PageRecord{})
+   }
+
+   records := history.Records()
+   if len(records) != HISTORY_MAX_PAGES {
+      t.Errorf("%d pages kept, want %d", len(records), HISTORY_MAX_PAGES)
+   }
+   for _, pageUrl := range []string{"current0", "current9", "previous" + strconv.Itoa(HISTORY_MAX_PAGES - 1), "previous10"} {
+      if _, existing := records[pageUrl]; !existing {
+         t.Errorf("page %s not kept", pageUrl)
+      }
+   }
+   for _, pageUrl := range []string{"previous0", "previous9"} {
+      if _, existing := records[pageUrl]; existing {
+         t.Errorf("least recently crawled page %s kept", pageUrl)
+      }
+   }
+}
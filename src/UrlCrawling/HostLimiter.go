@@ -0,0 +1,111 @@
+package UrlCrawling
+
+import (
+   "context"
+   "sync"
+   "time"
+)
+
+const HOST_SWEEP_INTERVAL = time.Minute // Minimum delay between two removals of the idle hosts from a host limiter
+
+/* Politeness state of a host:
+- active: number of requests in progress on the host,
+- next: earliest time the next request on the host may start,
+- released: channel closed (and replaced) each time a request on the host ends.*/
+type hostState struct {
+   active int
+   next time.Time
+   released chan struct{}
+}
+
+/* Per-host limiter: spacing the requests on a same host (values) by a minimum delay, and bounding their concurrency, per host (keys).
+The idle hosts are removed at most every HOST_SWEEP_INTERVAL, swept being the time of the last removal. */
+type HostLimiter struct {
+   sync.Mutex
+   hosts map[string]*hostState
+   swept time.Time
+}
+
+/* Host limiter shared by the crawling of all the jobs */
+var SharedHostLimiter = NewHostLimiter()
+
+
+
+/* HostLimiter creation.
+This method shall create a HostLimiter without any host.
+*/
+func NewHostLimiter() *HostLimiter {
+   return &HostLimiter{hosts:map[string]*hostState{}, swept:time.Now()}
+}
+
+/* Acquiring a request slot on a host.
+This method shall block until a request can be started on the specified host, so that:
+- the request starts at least the specified minimum delay after the previous request started on the host,
+- no more than the specified maximum number of requests are in progress on the host (no limit if not positive).
+It shall return the function to call once the request ended, or the context error if the specified context is done before.
+The hosts without request in progress, which minimum delay is elapsed, shall be forgotten from time to time, so that the limiter does not grow with
+all the hosts ever crawled.
+*/
+func (limiter *HostLimiter) Acquire(ctx context.Context, host string, minDelay time.Duration, maxConcurrent int) (func(), error) {
+   for {
+      limiter.Lock()
+      now := time.Now()
+      if now.Sub(limiter.swept) >= HOST_SWEEP_INTERVAL {
+         limiter.sweep(now)
+      }
+      state, existing := limiter.hosts[host]
+      if !existing {
+         state = &hostState{released:make(chan struct{})}
+         limiter.hosts[host] = state
+      }
+
+      concurrencyReached := (maxConcurrent > 0) && (state.active >= maxConcurrent)
+      if !concurrencyReached && !now.Before(state.next) {
+         // Slot available: reserving it
+         state.active++
+         state.next = now.Add(minDelay)
+         limiter.Unlock()
+         return func() { limiter.release(state) }, nil
+      }
+
+      // Waiting for a request to end on the host, or for the delay to be elapsed
+      released := state.released
+      wait := state.next.Sub(now)
+      limiter.Unlock()
+      if concurrencyReached {
+         select {
+            case <-released:
+            case <-ctx.Done():
+               return nil, ctx.Err()
+         }
+      } else {
+         timer := time.NewTimer(wait)
+         select {
+            case <-timer.C:
+            case <-ctx.Done():
+               timer.Stop()
+               return nil, ctx.Err()
+         }
+      }
+   }
+}
+
+// Helper function to release a request slot on a host, waking up the requests waiting for it
+func (limiter *HostLimiter) release(state *hostState) {
+   limiter.Lock()
+   state.active--
+   close(state.released)
+   state.released = make(chan struct{})
+   limiter.Unlock()
+}
+
+// Helper function to remove the idle hosts of the limiter at the specified time: no request in progress, and the minimum delay elapsed, so that
+// nobody waits for them. It shall be called with the limiter locked.
+func (limiter *HostLimiter) sweep(now time.Time) {
+   for host, state := range limiter.hosts {
+      if (state.active == 0) && !now.Before(state.next) {
+         delete(limiter.hosts, host)
+      }
+   }
+   limiter.swept = now
+}
@@ -0,0 +1,125 @@
+package UrlCrawling
+
+import (
+   "context"
+   "sync"
+   "testing"
+   "time"
+)
+
+func TestHostLimiterDelay(t *testing.T) {
+   tests := []struct {
+      delay time.Duration
+      maxConcurrent int
+   }{
+      {50 * time.Millisecond, 0},
+      {50 * time.Millisecond, 1},
+      {0, 0},
+   }
+   for _, test := range tests {
+      limiter := NewHostLimiter()
+      starts := []time.Time{}
+      for i := 0; i < 4; i++ {
+         release, err := limiter.Acquire(context.Background(), "example.com", test.delay, test.maxConcurrent)
+         if err != nil {
+            t.Fatal(err)
+         }
+         starts = append(starts, time.Now())
+         release()
+      }
+      for i := 1; i < len(starts); i++ {
+         // Tolerance for the time read once the slot is acquired
+         if spacing := starts[i].Sub(starts[i - 1]); spacing < test.delay - 5 * time.Millisecond {
+            t.Errorf("delay %v: requests %d and %d spaced by %v", test.delay, i - 1, i, spacing)
+         }
+      }
+   }
+
+   // Delay per host: another host not waiting
+   limiter := NewHostLimiter()
+   release, _ := limiter.Acquire(context.Background(), "a.example.com", time.Hour, 0)
+   release()
+   ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+   defer cancel()
+   if _, err := limiter.Acquire(ctx, "b.example.com", time.Hour, 0); err != nil {
+      t.Errorf("request on another host delayed: %v", err)
+   }
+}
+
+func TestHostLimiterConcurrency(t *testing.T) {
+   tests := []struct {
+      maxConcurrent int
+      want int
+   }{
+      {1, 1},
+      {2, 2},
+      {0, 6},
+   }
+   for _, test := range tests {
+      limiter := NewHostLimiter()
+      var lock sync.Mutex
+      active, maxActive := 0, 0
+      var requests sync.WaitGroup
+      for i := 0; i < 6; i++ {
+         requests.Add(1)
+         go func() {
+            defer requests.Done()
+            release, err := limiter.Acquire(context.Background(), "example.com", 0, test.maxConcurrent)
+            if err != nil {
+               t.Error(err)
+               return
+            }
+            lock.Lock()
+            active++
+            if active > maxActive {
+               maxActive = active
+            }
+            lock.Unlock()
+            time.Sleep(30 * time.Millisecond)
+            lock.Lock()
+            active--
+            lock.Unlock()
+            release()
+         }()
+      }
+      requests.Wait()
+      if maxActive != test.want {
+         t.Errorf("maximum %d concurrent requests: %d at the same time, want %d", test.maxConcurrent, maxActive, test.want)
+      }
+   }
+}
+
+func TestHostLimiterCancel(t *testing.T) {
+   limiter := NewHostLimiter()
+   release, _ := limiter.Acquire(context.Background(), "example.com", 0, 1)
+   defer release()
+   ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+   defer cancel()
+   if _, err := limiter.Acquire(ctx, "example.com", 0, 1); err != context.DeadlineExceeded {
+      t.Errorf("request waiting for a slot until its context is done: error %v, want %v", err, context.DeadlineExceeded)
+   }
+}
+
+func TestHostLimiterSweep(t *testing.T) {
+   limiter := NewHostLimiter()
+   release, _ := limiter.Acquire(context.Background(), "idle.example.com", 0, 0)
+   release()
+   _, _ = limiter.Acquire(context.Background(), "active.example.com", 0, 0)
+   release, _ = limiter.Acquire(context.Background(), "delayed.example.com", time.Hour, 0)
+   release()
+
+   // Sweep on the next request once the sweep interval is elapsed: only the idle host forgotten
+   limiter.Lock()
+   limiter.swept = time.Now().Add(-HOST_SWEEP_INTERVAL)
+   limiter.Unlock()
+   release, _ = limiter.Acquire(context.Background(), "new.example.com", 0, 0)
+   release()
+
+   limiter.Lock()
+   defer limiter.Unlock()
+   for host, want := range map[string]bool{"idle.example.com":false, "active.example.com":true, "delayed.example.com":true, "new.example.com":true} {
+      if _, existing := limiter.hosts[host]; existing != want {
+         t.Errorf("%s kept %v, want %v", host, existing, want)
+      }
+   }
+}
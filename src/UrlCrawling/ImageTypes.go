@@ -0,0 +1,214 @@
+package UrlCrawling
+
+import (
+   "bytes"
+   "container/list"
+   "context"
+   "io"
+   "mime"
+   "net/http"
+   "net/url"
+   "path"
+   "strconv"
+   "strings"
+   "sync"
+)
+
+const SNIFF_LENGTH = 512 // Number of bytes read to detect the type of an image from its content
+const SNIFF_CACHE_MAX_ENTRIES = 100000 // Maximum number of image types detected from their content kept by default
+
+/* Image types kept by default when a job does not specify any */
+var DEFAULT_IMAGE_TYPES = []string{"png", "gif", "jpeg", "webp", "svg", "avif"}
+
+/* Aliases (keys) of the image types (values) */
+var imageTypeAliases = map[string]string{
+   "jpg": "jpeg",
+   "jpe": "jpeg",
+   "jfif": "jpeg",
+   "pjpeg": "jpeg",
+   "svg+xml": "svg",
+   "svgz": "svg",
+   "tif": "tiff",
+   "x-icon": "ico",
+   "vnd.microsoft.icon": "ico",
+}
+
+/* Extensions which unambiguously give the type of an image */
+var imageExtensions = map[string]bool{
+   "png": true, "apng": true, "gif": true, "jpeg": true, "webp": true, "svg": true, "avif": true, "bmp": true, "ico": true, "tiff": true,
+}
+
+/* Image type detected from the response of an image URL */
+type sniffedImageType struct {
+   imageUrl string
+   imageType string
+}
+
+/* Types of images detected from their response (recency list elements), per image URL (keys), shared by all the jobs:
+- recency: detected types, the most recently used first, the least recently used ones being removed once more than maxEntries are kept.*/
+var sniffedImageTypes = struct {
+   sync.Mutex
+   types map[string]*list.Element
+   recency *list.List
+   maxEntries int
+}{types:map[string]*list.Element{}, recency:list.New(), maxEntries:SNIFF_CACHE_MAX_ENTRIES}
+
+
+
+/* Image type normalization.
+This method shall return the normalized image type of the specified extension, image type or image MIME type:
+lower case, without leading dot nor "image/" prefix, aliases replaced (jpg by jpeg, svg+xml by svg...).
+*/
+func NormalizeImageType(imageType string) string {
+   imageType = strings.ToLower(strings.TrimSpace(imageType))
+   imageType = strings.TrimPrefix(imageType, ".")
+   imageType = strings.TrimPrefix(imageType, "image/")
+   if alias, existing := imageTypeAliases[imageType]; existing {
+      return alias
+   }
+   return imageType
+}
+
+/* Image types set creation.
+This method shall return the set of the normalized specified image types, or of the default image types if none specified.
+*/
+func NewImageTypes(imageTypes []string) map[string]bool {
+   if len(imageTypes) == 0 {
+      imageTypes = DEFAULT_IMAGE_TYPES
+   }
+   imageTypesSet := map[string]bool{}
+   for _, imageType := range imageTypes {
+      imageTypesSet[NormalizeImageType(imageType)] = true
+   }
+   return imageTypesSet
+}
+
+// Helper function to get the normalized image type of a MIME type, empty if not an image
+func mimeImageType(mimeType string) string {
+   mediaType, _, err := mime.ParseMediaType(mimeType)
+   if (err != nil) || !strings.HasPrefix(mediaType, "image/") {
+      return ""
+   }
+   return NormalizeImageType(mediaType)
+}
+
+// Helper function to detect the image type from the first bytes of an image content, empty if not an image
+func sniffImageType(content []byte) string {
+   // AVIF images are not known from http.DetectContentType: "ftypavif" or "ftypavis" box at offset 4
+   if (len(content) >= 12) && bytes.Equal(content[4:8], []byte("ftyp")) && bytes.HasPrefix(content[8:12], []byte("avi")) {
+      return "avif"
+   }
+   if imageType := mimeImageType(http.DetectContentType(content)); imageType != "" {
+      return imageType
+   }
+   // SVG images are detected as text by http.DetectContentType
+   if bytes.Contains(bytes.ToLower(content), []byte("<svg")) {
+      return "svg"
+   }
+   return ""
+}
+
+/* Detecting an image type from its response.
+This method shall request the first bytes of the specified image URL through the shared host limiter, and return its normalized image type:
+from the response Content-Type if it is an image type, from the magic bytes of the content else. It shall return empty if not an image.
+The definitive answers (the type of an image read successfully, or no image for a missing one) shall be cached, to request each image only once,
+the transient failures (network error, server error, too many requests...) being detected again at the next request. It shall not be called
+while holding a host slot, the image slot being waited for.
+*/
+func (urlProcess *UrlProcess) DetectImageType(ctx context.Context, imageUrl *url.URL) string {
+   if imageType, existing := cachedImageType(imageUrl.String()); existing {
+      return imageType
+   }
+
+   release, err := SharedHostLimiter.Acquire(ctx, imageUrl.Host, urlProcess.Config.HostDelay, urlProcess.Config.HostMaxConcurrent)
+   if err != nil {
+      return ""
+   }
+   defer release()
+   request, err := http.NewRequestWithContext(ctx, http.MethodGet, imageUrl.String(), nil)
+   if err != nil {
+      return ""
+   }
+   request.Header.Set("Range", "bytes=0-" + strconv.Itoa(SNIFF_LENGTH - 1))
+   response, err := urlProcess.client().Do(request)
+   if err != nil {
+      return ""
+   }
+   defer response.Body.Close()
+
+   switch response.StatusCode {
+      case http.StatusOK, http.StatusPartialContent:
+         imageType := mimeImageType(response.Header.Get("Content-Type"))
+         if imageType == "" {
+            content := make([]byte, SNIFF_LENGTH)
+            length, err := io.ReadFull(response.Body, content)
+            urlProcess.countDownloaded(length)
+            if (err != nil) && (err != io.EOF) && (err != io.ErrUnexpectedEOF) {
+               // Content not read entirely: not a definitive answer
+               return ""
+            }
+            imageType = sniffImageType(content[:length])
+         }
+         cacheImageType(imageUrl.String(), imageType)
+         return imageType
+      case http.StatusNotFound, http.StatusGone:
+         // Missing image: definitively not an image
+         cacheImageType(imageUrl.String(), "")
+   }
+   return ""
+}
+
+// Helper function to get the cached image type of the specified image URL, and whether it is cached, marking it as the most recently used
+func cachedImageType(imageUrl string) (string, bool) {
+   sniffedImageTypes.Lock()
+   defer sniffedImageTypes.Unlock()
+   element, existing := sniffedImageTypes.types[imageUrl]
+   if !existing {
+      return "", false
+   }
+   sniffedImageTypes.recency.MoveToFront(element)
+   return element.Value.(*sniffedImageType).imageType, true
+}
+
+// Helper function to cache the specified image type of the specified image URL, removing the least recently used types beyond the maximum number
+func cacheImageType(imageUrl string, imageType string) {
+   sniffedImageTypes.Lock()
+   defer sniffedImageTypes.Unlock()
+   if element, existing := sniffedImageTypes.types[imageUrl]; existing {
+      element.Value.(*sniffedImageType).imageType = imageType
+      sniffedImageTypes.recency.MoveToFront(element)
+      return
+   }
+   sniffedImageTypes.types[imageUrl] = sniffedImageTypes.recency.PushFront(&sniffedImageType{imageUrl:imageUrl, imageType:imageType})
+   for (sniffedImageTypes.recency.Len() > 0) && (len(sniffedImageTypes.types) > sniffedImageTypes.maxEntries) {
+      oldest := sniffedImageTypes.recency.Back()
+      sniffedImageTypes.recency.Remove(oldest)
+      delete(sniffedImageTypes.types, oldest.Value.(*sniffedImageType).imageUrl)
+   }
+}
+
+/* Matching an image with the job image types.
+This method shall return whether the specified image URL is of one of the image types of the job:
+- for a data URL, from its media type,
+- when the extension of the URL path gives unambiguously the image type, from this extension, regardless of case and query string,
+- else (missing or ambiguous extension), from the response Content-Type or magic bytes if the image types verification is enabled for the job,
+  the image being dropped otherwise.
+*/
+func (urlProcess *UrlProcess) MatchImageType(ctx context.Context, imageUrl *url.URL) bool {
+   imageTypes := urlProcess.Config.ImageTypes
+
+   if imageUrl.Scheme == "data" {
+      mediaType := strings.SplitN(strings.SplitN(imageUrl.Opaque, ",", 2)[0], ";", 2)[0]
+      return imageTypes[mimeImageType(mediaType)]
+   }
+
+   extension := NormalizeImageType(path.Ext(imageUrl.Path))
+   if imageExtensions[extension] {
+      return imageTypes[extension]
+   }
+
+   if !urlProcess.Config.VerifyImageTypes {
+      return false
+   }
+   return imageTypes[urlProcess.DetectImageType(ctx, imageUrl)]
+}
@@ -0,0 +1,203 @@
+package UrlCrawling
+
+import (
+   "container/list"
+   "context"
+   "net/http"
+   "net/http/httptest"
+   "net/url"
+   "reflect"
+   "sync"
+   "testing"
+)
+
+// Helper function to empty the cache of the detected image types for a test, with the specified maximum number of entries, and restore it at the end
+// of the test
+func resetSniffedImageTypes(t *testing.T, maxEntries int) {
+   sniffedImageTypes.Lock()
+   types, recency, previousMaxEntries := sniffedImageTypes.types, sniffedImageTypes.recency, sniffedImageTypes.maxEntries
+   sniffedImageTypes.types, sniffedImageTypes.recency, sniffedImageTypes.maxEntries = map[string]*list.Element{}, list.New(), maxEntries
+   sniffedImageTypes.Unlock()
+   t.Cleanup(func() {
+      sniffedImageTypes.Lock()
+      sniffedImageTypes.types, sniffedImageTypes.recency, sniffedImageTypes.maxEntries = types, recency, previousMaxEntries
+      sniffedImageTypes.Unlock()
+   })
+}
+
+func TestNormalizeImageType(t *testing.T) {
+   tests := []struct {
+      imageType string
+      want string
+   }{
+      {"png", "png"},
+      {".JPG", "jpeg"},
+      {" image/svg+xml ", "svg"},
+      {"image/x-icon", "ico"},
+      {"tif", "tiff"},
+      {"", ""},
+   }
+   for _, test := range tests {
+      if got := NormalizeImageType(test.imageType); got != test.want {
+         t.Errorf("NormalizeImageType(%q) = %q, want %q", test.imageType, got, test.want)
+      }
+   }
+   if got, want := NewImageTypes([]string{"JPG", "image/png"}), map[string]bool{"jpeg":true, "png":true}; !reflect.DeepEqual(got, want) {
+      t.Errorf("NewImageTypes() = %v, want %v", got, want)
+   }
+   if got := NewImageTypes(nil); len(got) != len(DEFAULT_IMAGE_TYPES) {
+      t.Errorf("NewImageTypes(nil) = %v, want %v", got, DEFAULT_IMAGE_TYPES)
+   }
+}
+
+func TestSniffImageType(t *testing.T) {
+   tests := []struct {
+      content string
+      want string
+   }{
+      {"\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR", "png"},
+      {"GIF89a\x01\x00\x01\x00", "gif"},
+      {"\xff\xd8\xff\xe0\x00\x10JFIF", "jpeg"},
+      {"RIFF\x00\x00\x00\x00WEBPVP8 ", "webp"},
+      {"\x00\x00\x00\x1cftypavif\x00\x00\x00\x00", "avif"},
+      {`<?xml version="1.0"?><SVG xmlns="http://www.w3.org/2000/svg"></SVG>`, "svg"},
+      {"<html><body></body></html>", ""},
+      {"", ""},
+   }
+   for _, test := range tests {
+      if got := sniffImageType([]byte(test.content)); got != test.want {
+         t.Errorf("sniffImageType(%q) = %q, want %q", test.content, got, test.want)
+      }
+   }
+}
+
+func TestMatchImageType(t *testing.T) {
+   // Images without extension: typed by their Content-Type, or by their content
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      switch r.URL.Path {
+         case "/typed":
+            w.Header().Set("Content-Type", "image/png")
+            w.Write([]byte("not sniffed"))
+         case "/untyped":
+            w.Header().Set("Content-Type", "application/octet-stream")
+            w.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"))
+         case "/page":
+            w.Write([]byte("<html></html>"))
+         default:
+            http.NotFound(w, r)
+      }
+   }))
+   t.Cleanup(server.Close)
+   resetSniffedImageTypes(t, SNIFF_CACHE_MAX_ENTRIES)
+
+   tests := []struct {
+      url string
+      verify bool
+      want bool
+   }{
+      {"http://example.com/a.png", false, true},
+      {"http://example.com/a.PNG?size=2", false, true},
+      {"http://example.com/a.jpg", false, false},
+      {"data:image/png;base64,iVBORw0KGgo=", false, true},
+      {"data:image/gif;base64,R0lGODlh", false, false},
+      // Missing or ambiguous extension: detected only if verified
+      {server.URL + "/typed", false, false},
+      {server.URL + "/typed", true, true},
+      {server.URL + "/untyped", true, true},
+      {server.URL + "/page", true, false},
+      {server.URL + "/missing", true, false},
+   }
+   for _, test := range tests {
+      imageUrl, _ := url.Parse(test.url)
+      urlProcess := &UrlProcess{Config:&CrawlConfig{ImageTypes:NewImageTypes([]string{"png"}), VerifyImageTypes:test.verify}}
+      if got := urlProcess.MatchImageType(context.Background(), imageUrl); got != test.want {
+         t.Errorf("MatchImageType(%s) verified %v = %v, want %v", test.url, test.verify, got, test.want)
+      }
+   }
+}
+
+func TestDetectImageTypeCache(t *testing.T) {
+   // Images failing with the status codes (values) of their path (keys), until removed, the other ones being PNG images
+   var lock sync.Mutex
+   requests := map[string]int{}
+   failures := map[string]int{"/unavailable":http.StatusServiceUnavailable, "/throttled":http.StatusTooManyRequests, "/missing":http.StatusNotFound}
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      lock.Lock()
+      requests[r.URL.Path]++
+      statusCode, failing := failures[r.URL.Path]
+      lock.Unlock()
+      if failing {
+         w.WriteHeader(statusCode)
+         return
+      }
+      w.Header().Set("Content-Type", "image/png")
+      w.Write([]byte("image"))
+   }))
+   t.Cleanup(server.Close)
+   resetSniffedImageTypes(t, SNIFF_CACHE_MAX_ENTRIES)
+   urlProcess := &UrlProcess{Config:&CrawlConfig{}}
+
+   tests := []struct {
+      path string
+      requests int
+   }{
+      // Definitive answers requested once
+      {"/image", 1},
+      {"/missing", 1},
+      // Transient failures requested again
+      {"/unavailable", 2},
+      {"/throttled", 2},
+   }
+   for _, test := range tests {
+      imageUrl, _ := url.Parse(server.URL + test.path)
+      for i := 0; i < 2; i++ {
+         urlProcess.DetectImageType(context.Background(), imageUrl)
+      }
+      lock.Lock()
+      got := requests[test.path]
+      lock.Unlock()
+      if got != test.requests {
+         t.Errorf("%s detected twice: %d requests, want %d", test.path, got, test.requests)
+      }
+   }
+
+   // Image type detected once the transient failure over
+   lock.Lock()
+   delete(failures, "/unavailable")
+   lock.Unlock()
+   imageUrl, _ := url.Parse(server.URL + "/unavailable")
+   if got := urlProcess.DetectImageType(context.Background(), imageUrl); got != "png" {
+      t.Errorf("image available again detected as %q, want png", got)
+   }
+}
+
+func TestDetectImageTypeMaxEntries(t *testing.T) {
+   resetSniffedImageTypes(t, 2)
+   tests := []struct {
+      url string
+      cached bool
+   }{
+      {"http://example.com/a", false},
+      {"http://example.com/b", false},
+      // a used more recently than b
+      {"http://example.com/a", true},
+      // c exceeding the maximum number: b removed
+      {"http://example.com/c", false},
+      {"http://example.com/a", true},
+      {"http://example.com/b", false},
+   }
+   for i, test := range tests {
+      if _, cached := cachedImageType(test.url); cached != test.cached {
+         t.Errorf("lookup %d of %s: cached %v, want %v", i, test.url, cached, test.cached)
+      }
+      if !test.cached {
+         cacheImageType(test.url, "png")
+      }
+      sniffedImageTypes.Lock()
+      if (len(sniffedImageTypes.types) > 2) || (sniffedImageTypes.recency.Len() != len(sniffedImageTypes.types)) {
+         t.Errorf("lookup %d of %s: %d types kept, %d in the recency list, want 2 at most", i, test.url, len(sniffedImageTypes.types),
+            sniffedImageTypes.recency.Len())
+      }
+      sniffedImageTypes.Unlock()
+   }
+}
@@ -0,0 +1,126 @@
+package UrlCrawling
+
+import (
+   "errors"
+   "time"
+)
+
+// Retry policy used by default, for the jobs not specifying theirs
+const DEFAULT_MAX_ATTEMPTS = 3
+const DEFAULT_BASE_BACKOFF_MS = 1000
+const DEFAULT_MAX_BACKOFF_MS = 60000
+
+/* HTTP status codes of the responses retried by default */
+var DEFAULT_RETRYABLE_STATUSES = []int{408, 429, 500, 502, 503, 504}
+
+/* Retry policy of the URLs which could not be crawled because of a transient failure:
+- max_attempts: maximum number of attempts to crawl a URL, first one included, 1 disabling the retries,
+- base_backoff_ms: delay in milliseconds before the first retry, doubled at each retry,
+- max_backoff_ms: maximum delay in milliseconds before a retry,
+- retryable_statuses: HTTP status codes of the responses worth retrying, the timeouts and connection errors being always retried.*/
+type RetryPolicy struct {
+   MaxAttempts int `json:"max_attempts"`
+   BaseBackoffMs int `json:"base_backoff_ms"`
+   MaxBackoffMs int `json:"max_backoff_ms"`
+   RetryableStatuses []int `json:"retryable_statuses"`
+}
+
+
+
+/* Checking a retry policy.
+This method shall check the specified receiver retry policy, and complete it with the default values of its missing parameters: default maximum
+number of attempts, backoffs and retryable status codes if none specified (an empty list of status codes retrying none).
+An error shall be returned for negative values, or a maximum backoff lower than the base one.
+*/
+func (policy *RetryPolicy) ApplyDefaults() error {
+   if (policy.MaxAttempts < 0) || (policy.BaseBackoffMs < 0) || (policy.MaxBackoffMs < 0) {
+      return errors.New("retry policy values shall not be negative")
+   }
+   if policy.MaxAttempts == 0 {
+      policy.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+   }
+   if policy.BaseBackoffMs == 0 {
+      policy.BaseBackoffMs = DEFAULT_BASE_BACKOFF_MS
+   }
+   if policy.MaxBackoffMs == 0 {
+      policy.MaxBackoffMs = DEFAULT_MAX_BACKOFF_MS
+      if policy.MaxBackoffMs < policy.BaseBackoffMs {
+         policy.MaxBackoffMs = policy.BaseBackoffMs
+      }
+   }
+   if policy.MaxBackoffMs < policy.BaseBackoffMs {
+      return errors.New("max_backoff_ms shall not be lower than base_backoff_ms")
+   }
+   if policy.RetryableStatuses == nil {
+      policy.RetryableStatuses = append([]int{}, DEFAULT_RETRYABLE_STATUSES...)
+   }
+   return nil
+}
+
+/* Checking a failure.
+This method shall return whether the specified failure is transient according to the specified receiver retry policy: a timeout, a connection error,
+or a response which status code is retryable.
+*/
+func (policy *RetryPolicy) Retryable(failure *UrlFailure) bool {
+   switch failure.Class {
+      case FAILURE_TIMEOUT, FAILURE_CONNECTION:
+         return true
+      case FAILURE_HTTP:
+         for _, status := range policy.RetryableStatuses {
+            if status == failure.Status {
+               return true
+            }
+         }
+   }
+   return false
+}
+
+/* Retry backoff.
+This method shall return the delay before the specified retry (1 for the first one) according to the specified receiver retry policy:
+the base backoff doubled at each retry, up to the maximum backoff.
+*/
+func (policy *RetryPolicy) Backoff(retry int) time.Duration {
+   backoff := time.Duration(policy.BaseBackoffMs) * time.Millisecond
+   maxBackoff := time.Duration(policy.MaxBackoffMs) * time.Millisecond
+   for i := 1; (i < retry) && (backoff < maxBackoff); i++ {
+      backoff *= 2
+   }
+   if backoff > maxBackoff {
+      backoff = maxBackoff
+   }
+   return backoff
+}
+
+/* Retrying a failed URL.
+This method shall put the specified URL, crawled with the specified info and recorded as failed, back from the processing URLs set to the waiting URLs
+set of the specified receiver urlProcess, if its failure is transient and its attempts are not exhausted according to the retry policy of the job.
+The URL shall then not be recorded as failed anymore, and shall be waiting with its number of attempts so far, not to be crawled before the backoff
+delay. The info of the retry shall be returned, or false if the URL is not retried.
+*/
+func (urlProcess *UrlProcess) RetryUrl(failedUrl string, info *UrlInfo) (*UrlInfo, bool) {
+   policy := urlProcess.Config.Retry
+   if policy == nil {
+      return nil, false
+   }
+   urlProcess.Lock()
+   failure, failed := urlProcess.Failed[failedUrl]
+   if !failed || (info.Attempts + 1 >= policy.MaxAttempts) || !policy.Retryable(failure) {
+      urlProcess.Unlock()
+      return nil, false
+   }
+   delete(urlProcess.Failed, failedUrl)
+   urlProcess.Unlock()
+
+   notBefore := time.Now().Add(policy.Backoff(info.Attempts + 1))
+   retryInfo := &UrlInfo{Depth:info.Depth, LastMod:info.LastMod, Priority:info.Priority, Attempts:info.Attempts + 1, NotBefore:&notBefore}
+   waitingUrls := urlProcess.WaitingUrls
+   processingUrls := urlProcess.ProcessingUrls
+   waitingUrls.Lock()
+   processingUrls.Lock()
+   delete(processingUrls.UrlsData, failedUrl)
+   delete(processingUrls.UrlsInfo, failedUrl)
+   urlProcess.PushWaitingUrl(failedUrl, retryInfo)
+   processingUrls.Unlock()
+   waitingUrls.Unlock()
+   return retryInfo, true
+}
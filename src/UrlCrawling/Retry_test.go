@@ -0,0 +1,145 @@
+package UrlCrawling
+
+import (
+   "reflect"
+   "testing"
+   "time"
+)
+
+func TestRetryPolicyApplyDefaults(t *testing.T) {
+   tests := []struct {
+      policy RetryPolicy
+      want RetryPolicy
+      valid bool
+   }{
+      {RetryPolicy{}, RetryPolicy{3, 1000, 60000, DEFAULT_RETRYABLE_STATUSES}, true},
+      {RetryPolicy{MaxAttempts:1, RetryableStatuses:[]int{}}, RetryPolicy{1, 1000, 60000, []int{}}, true},
+      {RetryPolicy{BaseBackoffMs:120000}, RetryPolicy{3, 120000, 120000, DEFAULT_RETRYABLE_STATUSES}, true},
+      {RetryPolicy{5, 10, 100, []int{503}}, RetryPolicy{5, 10, 100, []int{503}}, true},
+      {RetryPolicy{MaxAttempts:-1}, RetryPolicy{}, false},
+      {RetryPolicy{BaseBackoffMs:-1}, RetryPolicy{}, false},
+      {RetryPolicy{MaxBackoffMs:-1}, RetryPolicy{}, false},
+      {RetryPolicy{BaseBackoffMs:2000, MaxBackoffMs:1000}, RetryPolicy{}, false},
+   }
+   for _, test := range tests {
+      policy := test.policy
+      err := policy.ApplyDefaults()
+      if (err == nil) != test.valid {
+         t.Errorf("%+v: error %v, want valid %v", test.policy, err, test.valid)
+         continue
+      }
+      if test.valid && !reflect.DeepEqual(policy, test.want) {
+         t.Errorf("%+v: completed into %+v, want %+v", test.policy, policy, test.want)
+      }
+   }
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+   policy := &RetryPolicy{RetryableStatuses:[]int{429, 503}}
+   tests := []struct {
+      failure *UrlFailure
+      want bool
+   }{
+      {&UrlFailure{Class:FAILURE_TIMEOUT}, true},
+      {&UrlFailure{Class:FAILURE_CONNECTION}, true},
+      {NewHttpFailure(429), true},
+      {NewHttpFailure(503), true},
+      {NewHttpFailure(500), false},
+      {NewHttpFailure(404), false},
+      {&UrlFailure{Class:FAILURE_DNS}, false},
+      {&UrlFailure{Class:FAILURE_TLS}, false},
+      {&UrlFailure{Class:FAILURE_INVALID_URL}, false},
+      {&UrlFailure{Class:FAILURE_REDIRECT}, false},
+      {&UrlFailure{Class:FAILURE_TOO_LARGE}, false},
+   }
+   for _, test := range tests {
+      if got := policy.Retryable(test.failure); got != test.want {
+         t.Errorf("Retryable(%+v) = %v, want %v", test.failure, got, test.want)
+      }
+   }
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+   policy := &RetryPolicy{BaseBackoffMs:1000, MaxBackoffMs:5000}
+   tests := []struct {
+      retry int
+      want time.Duration
+   }{
+      {1, time.Second},
+      {2, 2 * time.Second},
+      {3, 4 * time.Second},
+      {4, 5 * time.Second},
+      {100, 5 * time.Second},
+   }
+   for _, test := range tests {
+      if got := policy.Backoff(test.retry); got != test.want {
+         t.Errorf("Backoff(%d) = %v, want %v", test.retry, got, test.want)
+      }
+   }
+}
+
+func TestRetryUrl(t *testing.T) {
+   tests := []struct {
+      failure *UrlFailure
+      attempts int
+      retried bool
+   }{
+      {NewHttpFailure(503), 0, true},
+      {NewHttpFailure(503), 1, true},
+      {NewHttpFailure(503), 2, false},
+      {NewHttpFailure(404), 0, false},
+      {&UrlFailure{Class:FAILURE_TIMEOUT}, 0, true},
+      {nil, 0, false},
+   }
+   for _, test := range tests {
+      seed := "http://example.com/"
+      page := "http://example.com/page"
+      policy := &RetryPolicy{MaxAttempts:3, BaseBackoffMs:1000, MaxBackoffMs:1000}
+      policy.ApplyDefaults()
+      config := &CrawlConfig{Retry:policy}
+      frontier, _ := NewFrontier("")
+      urlProcess := &UrlProcess{}
+      urlProcess.InitUrlProcess(&seed, config, frontier)
+      frontier.Pop()
+      // Page popped from the frontier and crawled
+      info := &UrlInfo{Depth:1, Priority:0.8, Attempts:test.attempts}
+      urlProcess.WaitingUrls.Lock()
+      urlProcess.PushWaitingUrl(page, info)
+      urlProcess.WaitingUrls.Unlock()
+      frontier.Pop()
+      urlProcess.TakeWaitingUrl(page, info)
+      if test.failure != nil {
+         urlProcess.Failed[page] = test.failure
+      }
+
+      before := time.Now()
+      retryInfo, retried := urlProcess.RetryUrl(page, info)
+      if retried != test.retried {
+         t.Errorf("%+v after %d attempts: retried %v, want %v", test.failure, test.attempts, retried, test.retried)
+         continue
+      }
+      _, failed := urlProcess.Failure(page)
+      _, waiting := urlProcess.WaitingUrls.Urls[page]
+      _, processing := urlProcess.ProcessingUrls.UrlsInfo[page]
+      if !retried {
+         if (failed != (test.failure != nil)) || waiting || !processing {
+            t.Errorf("%+v after %d attempts not retried: failed %v, waiting %v, processing %v", test.failure, test.attempts, failed, waiting,
+               processing)
+         }
+         continue
+      }
+      if failed || !waiting || processing {
+         t.Errorf("%+v after %d attempts retried: failed %v, waiting %v, processing %v", test.failure, test.attempts, failed, waiting, processing)
+      }
+      if (retryInfo.Attempts != test.attempts + 1) || (retryInfo.Depth != 1) || (retryInfo.Priority != 0.8) {
+         t.Errorf("%+v after %d attempts: retry info %+v", test.failure, test.attempts, retryInfo)
+      }
+      if (retryInfo.NotBefore == nil) || retryInfo.NotBefore.Before(before.Add(time.Second)) {
+         t.Errorf("%+v after %d attempts: retry not before %v, want the backoff delay", test.failure, test.attempts, retryInfo.NotBefore)
+      }
+      // Not in the frontier before its backoff delay
+      if _, url, _, available := frontier.Pop(); available {
+         t.Errorf("%+v after %d attempts: %s popped from the frontier before the backoff delay", test.failure, test.attempts, url)
+      }
+   }
+}
@@ -0,0 +1,254 @@
+package UrlCrawling
+
+import (
+   "bufio"
+   "context"
+   "errors"
+   "io"
+   "net/http"
+   "net/url"
+   "regexp"
+   "strconv"
+   "strings"
+   "sync"
+   "time"
+)
+
+const DEFAULT_ROBOTS_USER_AGENT = "WebCrawler"
+const ROBOTS_PATH = "/robots.txt"
+const ROBOTS_TTL = 24 * time.Hour // Caching duration of a fetched robots.txt
+const ROBOTS_ERROR_TTL = time.Minute // Caching duration of a robots.txt the host failed to serve (5xx)
+const ROBOTS_TIMEOUT = 30 * time.Second // Maximum duration of a robots.txt request, whatever the timeouts of the job HTTP client
+
+/* Allow or Disallow rule of a robots.txt group, with its path pattern compiled */
+type robotsRule struct {
+   allow bool
+   pattern string
+   matcher *regexp.Regexp
+}
+
+/* Rules and crawl delay applying to a group of user-agents */
+type robotsGroup struct {
+   rules []robotsRule
+   crawlDelay time.Duration
+}
+
+/* Rules of a robots.txt per user-agent (keys, lower case), "*" for any user-agent, and the sitemaps it declares */
+type RobotsRules struct {
+   groups map[string]*robotsGroup
+   Sitemaps []string
+}
+
+/* Cached robots.txt of a host: ready is closed once the rules are fetched, err being set if they could not be */
+type robotsEntry struct {
+   ready chan struct{}
+   rules *RobotsRules
+   err error
+   expiry time.Time
+}
+
+/* Cache of the robots.txt rules (values) per scheme, host and request identity (keys), shared by all the jobs */
+var robotsCache = struct {
+   sync.Mutex
+   entries map[string]*robotsEntry
+}{entries:map[string]*robotsEntry{}}
+
+
+
+// Helper function to compile a robots.txt path pattern, where "*" matches any sequence of characters and a trailing "$" anchors the end of the path
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+   anchored := strings.HasSuffix(pattern, "$")
+   expr := regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+   expr = "^" + strings.Replace(expr, `\*`, ".*", -1)
+   if anchored {
+      expr += "$"
+   }
+   return regexp.MustCompile(expr)
+}
+
+/* robots.txt parsing.
+This method shall parse the specified robots.txt content into its rules:
+- consecutive User-agent lines shall start a group, the following Allow, Disallow and Crawl-delay lines applying to all the user-agents of the group,
+- empty Disallow lines shall be ignored (everything allowed),
+- Sitemap lines shall be collected whatever the group,
+- comments and unknown lines shall be ignored.
+*/
+func ParseRobots(content io.Reader) *RobotsRules {
+   robotsRules := &RobotsRules{groups:map[string]*robotsGroup{}}
+
+   var group *robotsGroup
+   groupStarted := false
+   scanner := bufio.NewScanner(content)
+   for scanner.Scan() {
+      // Removing comments, and splitting the line into its field and value
+      line := scanner.Text()
+      if commentPos := strings.Index(line, "#"); commentPos >= 0 {
+         line = line[:commentPos]
+      }
+      lineParts := strings.SplitN(line, ":", 2)
+      if len(lineParts) != 2 {
+         continue
+      }
+      field := strings.ToLower(strings.TrimSpace(lineParts[0]))
+      value := strings.TrimSpace(lineParts[1])
+
+      switch field {
+         case "user-agent":
+            // Starting a new group, unless following another User-agent line
+            if (group == nil) || groupStarted {
+               group = &robotsGroup{}
+               groupStarted = false
+            }
+            robotsRules.groups[strings.ToLower(value)] = group
+         case "allow", "disallow":
+            if (group == nil) || (value == "") {
+               continue
+            }
+            groupStarted = true
+            group.rules = append(group.rules, robotsRule{allow:(field == "allow"), pattern:value, matcher:compileRobotsPattern(value)})
+         case "crawl-delay":
+            if group == nil {
+               continue
+            }
+            groupStarted = true
+            if delay, err := strconv.ParseFloat(value, 64); err == nil && delay > 0 {
+               group.crawlDelay = time.Duration(delay * float64(time.Second))
+            }
+         case "sitemap":
+            if value != "" {
+               robotsRules.Sitemaps = append(robotsRules.Sitemaps, value)
+            }
+      }
+   }
+   return robotsRules
+}
+
+// Helper function to get the group applying to the specified user-agent: its own group if existing, the "*" group else
+func (robotsRules *RobotsRules) group(userAgent string) *robotsGroup {
+   if group, existing := robotsRules.groups[strings.ToLower(userAgent)]; existing {
+      return group
+   }
+   return robotsRules.groups["*"]
+}
+
+/* Checking robots.txt rules.
+This method shall return whether the specified URL may be crawled by the specified user-agent.
+The rule with the longest pattern matching the URL path shall apply, Allow winning over Disallow for patterns of same length.
+The URL shall be allowed if no rule matches.
+*/
+func (robotsRules *RobotsRules) Allowed(userAgent string, urlToCheck *url.URL) bool {
+   group := robotsRules.group(userAgent)
+   if group == nil {
+      return true
+   }
+
+   path := urlToCheck.EscapedPath()
+   if path == "" {
+      path = "/"
+   }
+   if urlToCheck.RawQuery != "" {
+      path += "?" + urlToCheck.RawQuery
+   }
+
+   allowed := true
+   matchLength := -1
+   for _, rule := range group.rules {
+      if !rule.matcher.MatchString(path) {
+         continue
+      }
+      if (len(rule.pattern) > matchLength) || ((len(rule.pattern) == matchLength) && rule.allow) {
+         allowed = rule.allow
+         matchLength = len(rule.pattern)
+      }
+   }
+   return allowed
+}
+
+/* Getting robots.txt Crawl-delay.
+This method shall return the Crawl-delay applying to the specified user-agent, 0 if none.
+*/
+func (robotsRules *RobotsRules) CrawlDelay(userAgent string) time.Duration {
+   group := robotsRules.group(userAgent)
+   if group == nil {
+      return 0
+   }
+   return group.crawlDelay
+}
+
+// Helper function to fetch the robots.txt of the specified host, returning the rules and their caching duration: everything allowed if not found (4xx),
+// everything disallowed if the host fails to serve it (5xx), or the error if the host cannot be reached
+func fetchRobots(ctx context.Context, client *http.Client, robotsUrl string) (*RobotsRules, time.Duration, error) {
+   ctx, cancel := context.WithTimeout(ctx, ROBOTS_TIMEOUT)
+   defer cancel()
+   request, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsUrl, nil)
+   if err != nil {
+      return nil, 0, err
+   }
+   response, err := client.Do(request)
+   if err != nil {
+      return nil, 0, err
+   }
+   defer response.Body.Close()
+
+   switch {
+      case response.StatusCode >= 500:
+         return &RobotsRules{groups:map[string]*robotsGroup{"*":{rules:[]robotsRule{{allow:false, pattern:"/", matcher:compileRobotsPattern("/")}}}}},
+            ROBOTS_ERROR_TTL, nil
+      case response.StatusCode >= 400:
+         return &RobotsRules{groups:map[string]*robotsGroup{}}, ROBOTS_TTL, nil
+   }
+   return ParseRobots(response.Body), ROBOTS_TTL, nil
+}
+
+/* Getting robots.txt rules.
+This method shall return the robots.txt rules of the host of the specified URL, fetching them with the specified HTTP client only if not cached yet
+or expired. The rules shall be cached per host and request identity (see ClientSettings.Identity), a host possibly serving another robots.txt to
+another user-agent or cookies. Concurrent callers for the same host and identity shall wait for a single fetch. An error shall be returned if the robots.txt cannot be fetched, the host being
+unreachable for instance; such an error shall not be cached, so that the next callers try again.
+*/
+func GetRobots(ctx context.Context, client *http.Client, identity string, hostUrl *url.URL) (*RobotsRules, error) {
+   robotsUrl := hostUrl.Scheme + "://" + hostUrl.Host + ROBOTS_PATH
+   cacheKey := robotsUrl + " " + identity
+
+   for {
+      robotsCache.Lock()
+      entry, existing := robotsCache.entries[cacheKey]
+      if existing {
+         // Checking the expiry only once fetched, the fetching caller setting it before closing ready
+         select {
+            case <-entry.ready:
+               // Expired: fetching again
+               existing = !time.Now().After(entry.expiry)
+            default:
+         }
+      }
+      if !existing {
+         entry = &robotsEntry{ready:make(chan struct{})}
+         robotsCache.entries[cacheKey] = entry
+         robotsCache.Unlock()
+
+         rules, ttl, err := fetchRobots(ctx, client, robotsUrl)
+         entry.rules, entry.err = rules, err
+         entry.expiry = time.Now().Add(ttl)
+         if ctx.Err() != nil {
+            // Fetch aborted: not keeping the result for the other jobs
+            entry.expiry = time.Now()
+            entry.err = ctx.Err()
+         }
+         close(entry.ready)
+         return entry.rules, entry.err
+      }
+      robotsCache.Unlock()
+
+      select {
+         case <-entry.ready:
+            if (entry.err != nil) && errors.Is(entry.err, context.Canceled) && (ctx.Err() == nil) {
+               // Fetch aborted by another job: fetching again
+               continue
+            }
+            return entry.rules, entry.err
+         case <-ctx.Done():
+            return nil, ctx.Err()
+      }
+   }
+}
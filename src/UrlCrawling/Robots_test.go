@@ -0,0 +1,186 @@
+package UrlCrawling
+
+import (
+   "context"
+   "net/http"
+   "net/http/httptest"
+   "net/url"
+   "strings"
+   "sync"
+   "testing"
+   "time"
+)
+
+func TestGetRobotsConcurrent(t *testing.T) {
+   fetches := 0
+   var lock sync.Mutex
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      lock.Lock()
+      fetches++
+      lock.Unlock()
+      // Slow robots.txt, so that the other callers look the entry up while it is fetched
+      time.Sleep(100 * time.Millisecond)
+      w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+   }))
+   defer server.Close()
+
+   hostUrl, _ := url.Parse(server.URL + "/")
+   privateUrl, _ := url.Parse(server.URL + "/private/page")
+   var callers sync.WaitGroup
+   for i := 0; i < 20; i++ {
+      callers.Add(1)
+      go func() {
+         defer callers.Done()
+         robotsRules, err := GetRobots(context.Background(), http.DefaultClient, "", hostUrl)
+         if err != nil {
+            t.Error(err)
+            return
+         }
+         if robotsRules.Allowed(DEFAULT_ROBOTS_USER_AGENT, privateUrl) {
+            t.Error("URL disallowed by robots.txt allowed")
+         }
+      }()
+      time.Sleep(10 * time.Millisecond)
+   }
+   callers.Wait()
+   if fetches != 1 {
+      t.Errorf("robots.txt fetched %d times, want 1", fetches)
+   }
+}
+
+func TestGetRobotsIdentity(t *testing.T) {
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      // robots.txt disallowing everything to the clients without the session cookie
+      if cookie, err := r.Cookie("session"); (err != nil) || (cookie.Value != "abc") || (r.Header.Get("User-Agent") != "TestBot") {
+         w.Write([]byte("User-agent: *\nDisallow: /\n"))
+         return
+      }
+      w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+   }))
+   defer server.Close()
+   pageUrl, _ := url.Parse(server.URL + "/page")
+
+   tests := []struct {
+      settings *ClientSettings
+      allowed bool
+   }{
+      {&ClientSettings{}, false},
+      {&ClientSettings{UserAgent:"TestBot", Cookies:[]*InitialCookie{{Name:"session", Value:"abc"}}, Seeds:[]string{server.URL + "/"}}, true},
+      {&ClientSettings{UserAgent:"TestBot"}, false},
+   }
+   for _, test := range tests {
+      robotsRules, err := GetRobots(context.Background(), NewHttpClient(test.settings), test.settings.Identity(), pageUrl)
+      if err != nil {
+         t.Errorf("%+v: unexpected error %v", test.settings, err)
+         continue
+      }
+      if got := robotsRules.Allowed(DEFAULT_ROBOTS_USER_AGENT, pageUrl); got != test.allowed {
+         t.Errorf("%+v: page allowed %v, want %v", test.settings, got, test.allowed)
+      }
+   }
+}
+
+func TestGetRobotsFailures(t *testing.T) {
+   statusCode := http.StatusServiceUnavailable
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      w.WriteHeader(statusCode)
+   }))
+   defer server.Close()
+   pageUrl, _ := url.Parse(server.URL + "/page")
+
+   // Host failing to serve its robots.txt: everything disallowed
+   robotsRules, err := GetRobots(context.Background(), http.DefaultClient, "", pageUrl)
+   if (err != nil) || robotsRules.Allowed(DEFAULT_ROBOTS_USER_AGENT, pageUrl) {
+      t.Errorf("robots.txt served with 503: err %v, want everything disallowed", err)
+   }
+
+   // Missing robots.txt: everything allowed
+   statusCode = http.StatusNotFound
+   missingUrl, _ := url.Parse(strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/page")
+   robotsRules, err = GetRobots(context.Background(), http.DefaultClient, "", missingUrl)
+   if (err != nil) || !robotsRules.Allowed(DEFAULT_ROBOTS_USER_AGENT, missingUrl) {
+      t.Errorf("robots.txt missing: err %v, want everything allowed", err)
+   }
+
+   // Unreachable host: error returned, and not cached
+   server.Close()
+   for i := 0; i < 2; i++ {
+      unreachableUrl, _ := url.Parse(server.URL + "/other/page")
+      unreachableUrl.Host = unreachableUrl.Hostname() + ":1"
+      if _, err := GetRobots(context.Background(), http.DefaultClient, "", unreachableUrl); err == nil {
+         t.Error("unreachable host robots.txt: error expected")
+      }
+   }
+}
+
+func TestParseRobots(t *testing.T) {
+   robotsRules := ParseRobots(strings.NewReader(`Disallow: /before-any-group
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /tmp/*/cache
+Allow: /same
+Disallow: /same
+Disallow:
+Crawl-delay: 2
+
+User-agent: WebCrawler
+user-agent: OtherBot
+Disallow: /nocrawler   # comment
+Crawl-delay: 0.5
+Sitemap: http://example.com/sitemap1.xml
+# Sitemap: http://example.com/commented.xml
+
+User-agent: *Bot
+Crawl-delay: invalid
+sitemap:http://example.com/sitemap2.xml
+`))
+   tests := []struct {
+      agent string
+      path string
+      allowed bool
+   }{
+      {"*", "/", true},
+      {"*", "/before-any-group", true},
+      {"*", "/private", false},
+      {"*", "/private/page", false},
+      {"*", "/privateer", false},
+      {"*", "/private/public/page", true},
+      {"*", "/doc.pdf", false},
+      {"*", "/dir/doc.pdf", false},
+      {"*", "/doc.pdf?page=2", true},
+      {"*", "/doc.pdfx", true},
+      {"*", "/tmp/a/b/cache/page", false},
+      {"*", "/tmp/cache", true},
+      {"*", "/same", true},
+      {"AnyBot", "/private", false},
+      {"WebCrawler", "/private", true},
+      {"webcrawler", "/nocrawler/page", false},
+      {"OtherBot", "/nocrawler", false},
+      {"OtherBot", "/private", true},
+   }
+   for _, test := range tests {
+      pathUrl, _ := url.Parse("http://example.com" + test.path)
+      if got := robotsRules.Allowed(test.agent, pathUrl); got != test.allowed {
+         t.Errorf("Allowed(%q, %q) = %v, want %v", test.agent, test.path, got, test.allowed)
+      }
+   }
+
+   delays := map[string]time.Duration{"*":2 * time.Second, "AnyBot":2 * time.Second, "WebCrawler":500 * time.Millisecond, "*bot":0}
+   for agent, want := range delays {
+      if got := robotsRules.CrawlDelay(agent); got != want {
+         t.Errorf("CrawlDelay(%q) = %v, want %v", agent, got, want)
+      }
+   }
+
+   sitemaps := []string{"http://example.com/sitemap1.xml", "http://example.com/sitemap2.xml"}
+   if (len(robotsRules.Sitemaps) != 2) || (robotsRules.Sitemaps[0] != sitemaps[0]) || (robotsRules.Sitemaps[1] != sitemaps[1]) {
+      t.Errorf("Sitemaps = %v, want %v", robotsRules.Sitemaps, sitemaps)
+   }
+
+   empty := ParseRobots(strings.NewReader(""))
+   if pathUrl, _ := url.Parse("http://example.com/private"); !empty.Allowed("*", pathUrl) || (empty.CrawlDelay("*") != 0) {
+      t.Error("empty robots.txt not allowing everything without delay")
+   }
+}
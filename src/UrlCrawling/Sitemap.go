@@ -0,0 +1,181 @@
+package UrlCrawling
+
+import (
+   "bufio"
+   "compress/gzip"
+   "context"
+   "encoding/xml"
+   "io"
+   "net/http"
+   "net/url"
+   "strconv"
+   "strings"
+)
+
+const SITEMAP_PATH = "/sitemap.xml"
+const SITEMAP_DEPTH = 1 // Depth given to the URLs found in sitemaps, as linked from the reference URL
+const DEFAULT_SITEMAP_PRIORITY = 0.5
+const MAX_SITEMAP_FILES = 100 // Maximum number of sitemaps and sitemap indexes fetched per reference URL
+const MAX_SITEMAP_URLS = 50000 // Maximum number of URLs seeded from sitemaps per reference URL
+const MAX_SITEMAP_SIZE = 50 << 20 // Maximum number of (uncompressed) bytes read from a sitemap
+
+/* Entry of a sitemap (<url> tag) or of a sitemap index (<sitemap> tag) */
+type sitemapEntry struct {
+   Loc string `xml:"loc"`
+   LastMod string `xml:"lastmod"`
+   Priority string `xml:"priority"`
+}
+
+/* Sitemap (<urlset> root tag with <url> entries) or sitemap index (<sitemapindex> root tag with <sitemap> entries) */
+type sitemapDocument struct {
+   XMLName xml.Name
+   Urls []sitemapEntry `xml:"url"`
+   Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+
+
+/* Sitemap parsing.
+This method shall parse the specified sitemap or sitemap index content, gzipped or not, and return its URL entries and its sitemap entries.
+*/
+func parseSitemap(content io.Reader) (*sitemapDocument, error) {
+   bufferedContent := bufio.NewReader(content)
+   // Detecting gzipped sitemaps from their magic bytes, whatever their extension or Content-Type
+   if magic, err := bufferedContent.Peek(2); (err == nil) && (magic[0] == 0x1f) && (magic[1] == 0x8b) {
+      gzipContent, err := gzip.NewReader(bufferedContent)
+      if err != nil {
+         return nil, err
+      }
+      defer gzipContent.Close()
+      content = gzipContent
+   } else {
+      content = bufferedContent
+   }
+
+   document := &sitemapDocument{}
+   err := xml.NewDecoder(io.LimitReader(content, MAX_SITEMAP_SIZE)).Decode(document)
+   return document, err
+}
+
+// Helper function to fetch and parse a sitemap through the shared host limiter
+func (urlProcess *UrlProcess) fetchSitemap(ctx context.Context, sitemapUrl *url.URL) (*sitemapDocument, error) {
+   release, err := SharedHostLimiter.Acquire(ctx, sitemapUrl.Host, urlProcess.Config.HostDelay, urlProcess.Config.HostMaxConcurrent)
+   if err != nil {
+      return nil, err
+   }
+   defer release()
+
+   request, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapUrl.String(), nil)
+   if err != nil {
+      return nil, err
+   }
+   response, err := urlProcess.client().Do(request)
+   if err != nil {
+      return nil, err
+   }
+   defer response.Body.Close()
+   if response.StatusCode != http.StatusOK {
+      return nil, errUnexpectedStatus
+   }
+   return parseSitemap(response.Body)
+}
+
+/* Seeding from sitemaps.
+This method shall discover the sitemaps of the reference URL host, the ones declared in its robots.txt and its /sitemap.xml, and add the URLs they
+list to the waiting URLs set of the specified receiver urlProcess, with their lastmod and priority, at a depth of 1 from the reference URL.
+The sitemap indexes shall be followed recursively, and gzipped sitemaps read. Only the URLs of the reference URL host, allowed by robots.txt and
+not seen yet shall be added, up to a maximum number of URLs and sitemaps. Nothing shall be added if the robots.txt of the host cannot be fetched.
+It shall return the number of URLs added.
+*/
+func (urlProcess *UrlProcess) SeedFromSitemaps(ctx context.Context) int {
+   refUrl := urlProcess.DomainUrl
+   robotsAgent := urlProcess.Config.RobotsAgent
+   robotsRules, err := GetRobots(ctx, urlProcess.client(), urlProcess.Config.Identity, refUrl)
+   if err != nil {
+      return 0
+   }
+
+   // Sitemaps to fetch: the ones of robots.txt, then the default one
+   sitemapsToFetch := []string{}
+   for _, sitemap := range robotsRules.Sitemaps {
+      sitemapsToFetch = append(sitemapsToFetch, sitemap)
+   }
+   sitemapsToFetch = append(sitemapsToFetch, refUrl.Scheme + "://" + refUrl.Host + SITEMAP_PATH)
+
+   seeded := 0
+   fetched := map[string]bool{}
+   for (len(sitemapsToFetch) > 0) && (len(fetched) < MAX_SITEMAP_FILES) && (seeded < MAX_SITEMAP_URLS) && (ctx.Err() == nil) {
+      sitemap := sitemapsToFetch[0]
+      sitemapsToFetch = sitemapsToFetch[1:]
+      sitemapUrl, err := ParseUrl(&sitemap)
+      if (err != nil) || fetched[sitemapUrl.String()] || ((sitemapUrl.Scheme != "http") && (sitemapUrl.Scheme != "https")) {
+         continue
+      }
+      fetched[sitemapUrl.String()] = true
+
+      document, err := urlProcess.fetchSitemap(ctx, sitemapUrl)
+      if err != nil {
+         continue
+      }
+
+      // Sitemap index: following its sitemaps
+      for _, entry := range document.Sitemaps {
+         if loc := strings.TrimSpace(entry.Loc); loc != "" {
+            sitemapsToFetch = append(sitemapsToFetch, loc)
+         }
+      }
+
+      // Sitemap: seeding its URLs
+      for _, entry := range document.Urls {
+         if seeded >= MAX_SITEMAP_URLS {
+            break
+         }
+         loc := strings.TrimSpace(entry.Loc)
+         locUrl, err := ParseUrl(&loc)
+         if (err != nil) || (locUrl.Host != refUrl.Host) {
+            continue
+         }
+         if !robotsRules.Allowed(robotsAgent, locUrl) {
+            urlProcess.skipByRobots(locUrl.String())
+            continue
+         }
+         priority, err := strconv.ParseFloat(strings.TrimSpace(entry.Priority), 64)
+         if (err != nil) || (priority < 0) || (priority > 1) {
+            priority = DEFAULT_SITEMAP_PRIORITY
+         }
+         if urlProcess.seedUrl(locUrl.String(), &UrlInfo{Depth:SITEMAP_DEPTH, LastMod:strings.TrimSpace(entry.LastMod), Priority:priority}) {
+            seeded++
+         }
+      }
+   }
+   if seeded > 0 {
+      urlProcess.notifyPushed()
+   }
+   return seeded
+}
+
+// Helper function to add a URL found in a sitemap to the waiting URLs set if not seen yet, or to record its sitemap info if already waiting
+func (urlProcess *UrlProcess) seedUrl(seedUrl string, info *UrlInfo) bool {
+   processingUrls := urlProcess.ProcessingUrls
+   crawledUrls := urlProcess.CrawledUrls
+   processingUrls.Lock()
+   _, alreadyProcessing := processingUrls.UrlsData[seedUrl]
+   processingUrls.Unlock()
+   crawledUrls.Lock()
+   _, alreadyCrawled := crawledUrls.UrlsData[seedUrl]
+   crawledUrls.Unlock()
+   if alreadyProcessing || alreadyCrawled {
+      return false
+   }
+
+   waitingUrls := urlProcess.WaitingUrls
+   waitingUrls.Lock()
+   defer waitingUrls.Unlock()
+   if waitingInfo, alreadyWaiting := waitingUrls.Urls[seedUrl]; alreadyWaiting {
+      // Keeping the depth of the waiting URL, pushed again so that the frontier takes its sitemap info into account
+      urlProcess.PushWaitingUrl(seedUrl, &UrlInfo{Depth:waitingInfo.Depth, LastMod:info.LastMod, Priority:info.Priority})
+      return false
+   }
+   urlProcess.PushWaitingUrl(seedUrl, info)
+   return true
+}
@@ -0,0 +1,120 @@
+package UrlCrawling
+
+import (
+   "bytes"
+   "compress/gzip"
+   "context"
+   "net/http"
+   "net/http/httptest"
+   "reflect"
+   "strings"
+   "testing"
+)
+
+// Helper function to gzip a content
+func gzipped(content string) string {
+   var buffer bytes.Buffer
+   writer := gzip.NewWriter(&buffer)
+   writer.Write([]byte(content))
+   writer.Close()
+   return buffer.String()
+}
+
+func TestParseSitemap(t *testing.T) {
+   urlset := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://example.com/a</loc><lastmod>2024-01-02</lastmod><priority>0.8</priority></url>
+  <url><loc> http://example.com/b </loc></url>
+</urlset>`
+   index := `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>http://example.com/s1.xml</loc><lastmod>2024-01-01</lastmod></sitemap>
+  <sitemap><loc>http://example.com/s2.xml.gz</loc></sitemap>
+</sitemapindex>`
+   tests := []struct {
+      name string
+      content string
+      root string
+      urls []sitemapEntry
+      sitemaps []sitemapEntry
+      fails bool
+   }{
+      {"urlset", urlset, "urlset", []sitemapEntry{{Loc:"http://example.com/a", LastMod:"2024-01-02", Priority:"0.8"}, {Loc:" http://example.com/b "}},
+         nil, false},
+      {"gzipped urlset", gzipped(urlset), "urlset", []sitemapEntry{{Loc:"http://example.com/a", LastMod:"2024-01-02", Priority:"0.8"},
+         {Loc:" http://example.com/b "}}, nil, false},
+      {"sitemap index", index, "sitemapindex", nil, []sitemapEntry{{Loc:"http://example.com/s1.xml", LastMod:"2024-01-01"},
+         {Loc:"http://example.com/s2.xml.gz"}}, false},
+      {"empty urlset", "<urlset></urlset>", "urlset", nil, nil, false},
+      {"not XML", "User-agent: *", "", nil, nil, true},
+      {"truncated", `<urlset><url><loc>http://example.com/a`, "", nil, nil, true},
+      {"corrupted gzip", "\x1f\x8bnot gzip", "", nil, nil, true},
+   }
+   for _, test := range tests {
+      document, err := parseSitemap(strings.NewReader(test.content))
+      if test.fails {
+         if err == nil {
+            t.Errorf("%s: error expected", test.name)
+         }
+         continue
+      }
+      if err != nil {
+         t.Errorf("%s: unexpected error %v", test.name, err)
+         continue
+      }
+      if (document.XMLName.Local != test.root) || !reflect.DeepEqual(document.Urls, test.urls) || !reflect.DeepEqual(document.Sitemaps, test.sitemaps) {
+         t.Errorf("%s: parsed %s %+v %+v, want %s %+v %+v", test.name, document.XMLName.Local, document.Urls, document.Sitemaps, test.root, test.urls,
+            test.sitemaps)
+      }
+   }
+}
+
+func TestSeedFromSitemaps(t *testing.T) {
+   var server *httptest.Server
+   server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      switch r.URL.Path {
+         case "/robots.txt":
+            w.Write([]byte("User-agent: *\nDisallow: /private\nSitemap: " + server.URL + "/index.xml\n"))
+         case "/index.xml":
+            w.Write([]byte(`<sitemapindex><sitemap><loc>` + server.URL + `/pages.xml.gz</loc></sitemap>
+               <sitemap><loc>` + server.URL + `/index.xml</loc></sitemap></sitemapindex>`))
+         case "/pages.xml.gz":
+            w.Write([]byte(gzipped(`<urlset>
+               <url><loc>` + server.URL + `/a</loc><lastmod>2024-01-02</lastmod><priority>0.9</priority></url>
+               <url><loc>` + server.URL + `/b</loc><priority>2</priority></url>
+               <url><loc>` + server.URL + `/private/c</loc></url>
+               <url><loc>http://other.example.com/d</loc></url></urlset>`)))
+         case "/sitemap.xml":
+            w.Write([]byte(`<urlset><url><loc>` + server.URL + `/a</loc></url><url><loc>` + server.URL + `/e</loc></url></urlset>`))
+         default:
+            http.NotFound(w, r)
+      }
+   }))
+   defer server.Close()
+
+   seed := server.URL + "/"
+   frontier, _ := NewFrontier("")
+   urlProcess := &UrlProcess{}
+   urlProcess.InitUrlProcess(&seed, &CrawlConfig{HostMaxConcurrent:1}, frontier)
+   if seeded := urlProcess.SeedFromSitemaps(context.Background()); seeded != 3 {
+      t.Errorf("%d URLs seeded, want 3", seeded)
+   }
+
+   want := map[string]UrlInfo{
+      seed: {Depth:0},
+      server.URL + "/a": {Depth:SITEMAP_DEPTH, LastMod:"2024-01-02", Priority:0.9},
+      server.URL + "/b": {Depth:SITEMAP_DEPTH, Priority:DEFAULT_SITEMAP_PRIORITY},
+      server.URL + "/e": {Depth:SITEMAP_DEPTH, Priority:DEFAULT_SITEMAP_PRIORITY},
+   }
+   waitingUrls := urlProcess.WaitingUrls.Urls
+   if len(waitingUrls) != len(want) {
+      t.Errorf("waiting URLs %v, want %v", waitingUrls, want)
+   }
+   for waitingUrl, info := range want {
+      if got, waiting := waitingUrls[waitingUrl]; !waiting || (*got != info) {
+         t.Errorf("%s: waiting %v with %+v, want %+v", waitingUrl, waiting, got, info)
+      }
+   }
+   if !urlProcess.RobotsSkipped[server.URL + "/private/c"] {
+      t.Error("URL disallowed by robots.txt not recorded as skipped")
+   }
+}
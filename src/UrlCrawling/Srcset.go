@@ -0,0 +1,70 @@
+package UrlCrawling
+
+import (
+   "strings"
+   "unicode"
+)
+
+/* Image candidate of a srcset attribute:
+- Url: URL of the image, as written in the attribute,
+- Descriptor: width ("640w") or pixel density ("2x") descriptor, empty if none.*/
+type SrcsetCandidate struct {
+   Url string
+   Descriptor string
+}
+
+
+
+/* srcset parsing.
+This method shall parse the specified srcset attribute value into its image candidates, as per the HTML specification:
+- candidates are separated by commas, each one being a URL optionally followed by whitespaces and a descriptor,
+- a URL may contain commas (data URLs for instance), only the trailing commas of a URL separating it from the next candidate,
+- commas inside parentheses of a descriptor do not separate candidates.
+*/
+func ParseSrcset(srcset string) []SrcsetCandidate {
+   candidates := []SrcsetCandidate{}
+   position := 0
+   for position < len(srcset) {
+      // Skipping the whitespaces and commas before the URL
+      for (position < len(srcset)) && (unicode.IsSpace(rune(srcset[position])) || (srcset[position] == ',')) {
+         position++
+      }
+      if position >= len(srcset) {
+         break
+      }
+
+      // Collecting the URL until a whitespace
+      urlStart := position
+      for (position < len(srcset)) && !unicode.IsSpace(rune(srcset[position])) {
+         position++
+      }
+      candidateUrl := srcset[urlStart:position]
+
+      // URL ending with commas: no descriptor
+      if strings.HasSuffix(candidateUrl, ",") {
+         candidateUrl = strings.TrimRight(candidateUrl, ",")
+         if candidateUrl != "" {
+            candidates = append(candidates, SrcsetCandidate{Url:candidateUrl})
+         }
+         continue
+      }
+
+      // Collecting the descriptor until a comma outside parentheses
+      descriptorStart := position
+      inParentheses := false
+      for position < len(srcset) {
+         char := srcset[position]
+         if char == '(' {
+            inParentheses = true
+         } else if char == ')' {
+            inParentheses = false
+         } else if (char == ',') && !inParentheses {
+            break
+         }
+         position++
+      }
+      descriptor := strings.Join(strings.Fields(srcset[descriptorStart:position]), " ")
+      candidates = append(candidates, SrcsetCandidate{Url:candidateUrl, Descriptor:descriptor})
+   }
+   return candidates
+}
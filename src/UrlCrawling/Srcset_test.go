@@ -0,0 +1,33 @@
+package UrlCrawling
+
+import (
+   "reflect"
+   "testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+   tests := []struct {
+      srcset string
+      want []SrcsetCandidate
+   }{
+      {"", []SrcsetCandidate{}},
+      {" , ", []SrcsetCandidate{}},
+      {"a.png", []SrcsetCandidate{{Url:"a.png"}}},
+      {"a.png 1x, b.png 2x", []SrcsetCandidate{{Url:"a.png", Descriptor:"1x"}, {Url:"b.png", Descriptor:"2x"}}},
+      {"a.png 640w,b.png 1280w", []SrcsetCandidate{{Url:"a.png", Descriptor:"640w"}, {Url:"b.png", Descriptor:"1280w"}}},
+      {"  a.png  ,\n\tb.png   2x  ", []SrcsetCandidate{{Url:"a.png"}, {Url:"b.png", Descriptor:"2x"}}},
+      {"a.png,, b.png 2x", []SrcsetCandidate{{Url:"a.png"}, {Url:"b.png", Descriptor:"2x"}}},
+      // Commas inside a URL do not separate candidates
+      {"a.png,b.png", []SrcsetCandidate{{Url:"a.png,b.png"}}},
+      {"data:image/png;base64,AAAA 1x, b.png 2x", []SrcsetCandidate{{Url:"data:image/png;base64,AAAA", Descriptor:"1x"}, {Url:"b.png", Descriptor:"2x"}}},
+      {"/img?w=1,2 100w", []SrcsetCandidate{{Url:"/img?w=1,2", Descriptor:"100w"}}},
+      // Commas inside parentheses of a descriptor do not separate candidates
+      {"a.png (min-width: 1px, max-width: 2px) 1x, b.png", []SrcsetCandidate{{Url:"a.png", Descriptor:"(min-width: 1px, max-width: 2px) 1x"},
+         {Url:"b.png"}}},
+   }
+   for _, test := range tests {
+      if got := ParseSrcset(test.srcset); !reflect.DeepEqual(got, test.want) {
+         t.Errorf("ParseSrcset(%q) = %+v, want %+v", test.srcset, got, test.want)
+      }
+   }
+}
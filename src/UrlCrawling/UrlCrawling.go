@@ -1,43 +1,138 @@
 package UrlCrawling
 
 import (
+   "bytes"
+   "context"
    "fmt"
    "golang.org/x/net/html"
+   "io"
+   "io/ioutil"
    "net/http"
    "net/url"
+   "sort"
    "strings"
    "sync"
+   "time"
 )
 
-const LINKS_LEVEL = 2 //Crawling depth
+const DEFAULT_MAX_DEPTH = 2 //Crawling depth
+const DETAILS_SEPARATOR = ", " // Separator of the details of a record value found several times
 
-/* Map between URLs (keys) and their data/images (values) */
+/* Info attached to a URL to crawl:
+- Depth: number of hops (followed links) from the reference URL to the URL,
+- LastMod: last modification date of the URL as per the sitemap listing it, empty if none,
+- Priority: priority of the URL as per the sitemap listing it, 0 if not listed in a sitemap,
+- Attempts: number of attempts to crawl the URL which failed so far,
+- NotBefore: time before which the URL shall not be crawled, when waiting to be retried, none if nil.*/
+type UrlInfo struct {
+   Depth int `json:"depth"`
+   LastMod string `json:"lastmod,omitempty"`
+   Priority float64 `json:"priority,omitempty"`
+   Attempts int `json:"attempts,omitempty"`
+   NotBefore *time.Time `json:"not_before,omitempty"`
+}
+
+/* Data extracted from a page: records values (keys) with their detail (values), per extractor name (keys) */
+type PageData map[string]map[string]string
+
+/* Map between URLs (keys) and their extracted data (values), with the info of these URLs */
 type MapUrlsData struct {
    sync.Mutex
-   UrlsData map[string]map[string]string
+   UrlsData map[string]PageData
+   UrlsInfo map[string]*UrlInfo
 }
 
-/* Slice of URLs */
+/* Set of URLs (keys) with their info (values) */
 type Urls struct {
    sync.Mutex
-   Urls map[string]string
+   Urls map[string]*UrlInfo
+}
+
+/* Crawling settings of a job, shared by the processing info of all its URLs:
+- RobotsAgent: user-agent token which robots.txt rules are honoured,
+- HostDelay: minimum delay between two requests on a same host,
+- HostMaxConcurrent: maximum number of concurrent requests on a same host,
+- MaxDepth: maximum number of hops from the reference URL of the crawled URLs,
+- ImageTypes: set of the normalized image types to keep,
+- VerifyImageTypes: whether the type of the images with missing or ambiguous extension is detected from their response,
+- Extractors: extractors of the data collected from the crawled pages,
+- UseSitemaps: whether the waiting URLs are seeded from the sitemaps of the reference URL host,
+- FetchCacheTTL: caching duration of the crawled pages in the fetch cache shared by all the jobs, the cache not being used if 0,
+- Identity: request identity of the job (see ClientSettings.Identity), the pages of the fetch cache being shared only by the jobs of the same identity,
+- History: pages crawled by the last crawl of the same seed set, and recorded by the ongoing crawl, none if nil,
+- Stylesheets: stylesheets fetched for the job, the stylesheets not being cached if nil,
+- Retry: retry policy of the URLs which could not be crawled, none retried if nil,
+- Client: HTTP client of the job, http.DefaultClient if nil,
+- MaxBodySize: maximum number of bytes of a crawled page, none if 0,
+- Notify: function called, if any, when URLs are pushed in the waiting URLs sets, so that the workers sleeping for lack of URLs to crawl wake up.*/
+type CrawlConfig struct {
+   RobotsAgent string
+   HostDelay time.Duration
+   HostMaxConcurrent int
+   MaxDepth int
+   ImageTypes map[string]bool
+   VerifyImageTypes bool
+   Extractors []Extractor
+   UseSitemaps bool
+   FetchCacheTTL time.Duration
+   Identity string
+   History *CrawlHistory
+   Stylesheets *StylesheetCache
+   Retry *RetryPolicy
+   Client *http.Client
+   MaxBodySize int64
+   Notify func()
 }
 
 /* Processing Info related to a specific URL:
+- Config: crawling settings of the job,
+- Seed: the specific URL, as defined in the job,
 - DomainUrl: parsed URL of the specific URL,
+- Frontier: frontier of the job, shared by all its URLs, ordering the URLs pushed in the WaitingUrls set,
 - WaitingUrls: related URLs waiting to be crawled, added when the specific URL and its related URLs are crawled,
 - CompletedUrls: related URLs crawled among those previously in the WaitingUrls set,
-- ProcessingUrls: related URLs being crawled, so not belonging to the WaitingUrls set anymore, and not yet belonging to the CompletedUrls set.*/
+- ProcessingUrls: related URLs being crawled, so not belonging to the WaitingUrls set anymore, and not yet belonging to the CompletedUrls set,
+- RobotsSkipped: related URLs not crawled because disallowed by robots.txt rules, protected by the UrlProcess lock,
+- Unchanged: related URLs not modified since the last crawl of the seed set, protected by the UrlProcess lock,
+- Failed: related URLs (keys) which could not be crawled, with their failure (values), protected by the UrlProcess lock,
+- Statuses: related URLs (keys) which got a response, with the HTTP status code of their last response (values), protected by the UrlProcess lock,
+- Downloaded: number of bytes downloaded when crawling the related URLs, pages and assets, protected by the UrlProcess lock.*/
 type UrlProcess struct {
    sync.Mutex
+   Config *CrawlConfig
+   Seed string
    DomainUrl *url.URL
+   Frontier Frontier
    WaitingUrls *Urls
    CrawledUrls *MapUrlsData
    ProcessingUrls *MapUrlsData
+   RobotsSkipped map[string]bool
+   Unchanged map[string]bool
+   Failed map[string]*UrlFailure
+   Statuses map[string]int
+   Downloaded int64
 }
 
+/* Checkpoint of the processing info related to a specific URL, as a copy of its WaitingUrls, ProcessingUrls (info only) and CrawledUrls sets */
+type UrlProcessCheckpoint struct {
+   WaitingUrls map[string]*UrlInfo `json:"waiting_urls"`
+   ProcessingUrls map[string]*UrlInfo `json:"processing_urls"`
+   CrawledUrls map[string]PageData `json:"crawled_urls"`
+   RobotsSkipped []string `json:"robots_skipped,omitempty"`
+   Unchanged []string `json:"unchanged,omitempty"`
+   Failed map[string]*UrlFailure `json:"failed,omitempty"`
+   Statuses map[string]int `json:"statuses,omitempty"`
+   Downloaded int64 `json:"downloaded,omitempty"`
+}
 
 
+
+// Helper function to get the value of the specified tag from a Token, empty if not found
+func getTokenAttribute(token html.Token, tag string) string {
+   _, val := getTokenValue(token, tag)
+   return val
+}
+
 // Helper function to get the value of the specified tag from a Token
 func getTokenValue(token html.Token, tag string) (tagFound bool, val string) {
    // Iterate over token attributes until we find the specified tag
@@ -51,37 +146,95 @@ func getTokenValue(token html.Token, tag string) (tagFound bool, val string) {
 }
 
 /* Crawling a URL page.
-This method shall crawl the specified URL to get its data (images) and new URLs to crawl.
+This method shall crawl the specified URL, found at the specified depth from the reference URL, to get its data and new URLs to crawl.
 It shall read the content body of the specified URL, and terminates the function if an error is raised or if the end of URL is reached.
+The request shall be bound to the specified context, so that cancelling the context aborts an in-flight crawl.
+The specified URL shall not be crawled if disallowed by the robots.txt rules of its host, and shall be recorded as failed, with its failure (HTTP status,
+error class and message), if it cannot be fetched or if its response is not 2xx; such a response shall not be gone through. It shall be requested
+with the HTTP client of the job, and shall be recorded as failed too if its body is larger than the job maximum or cannot be read till its end.
+It shall be requested through the shared host limiter, so that the requests on its host are spaced by the job host delay, or by the robots.txt Crawl-delay
+if longer, and are not more concurrent than the job maximum per host. Its host slot shall be released once its body is read, before going through it,
+so that its stylesheets and images fetched meanwhile do not wait for a slot held by the page itself.
+If the fetch cache is enabled for the job, the page shall be taken from the cache shared by all the jobs, and only fetched if not cached yet or expired;
+its links and data shall still be collected for the specified receiver urlProcess.
+If the page was crawled by the last crawl of the seed set with an ETag or Last-Modified validator, a conditional request shall be sent instead:
+on 304 Not Modified, its previous links and data shall be reused, and the URL recorded as unchanged. The crawled page shall be recorded in the
+crawl history of the job, with its validators, links and data.
 Else, it shall go through the specified URL and:
 - add found URLs to the waiting URLs set of the specified receiver urlProcess if the following conditions are met:
-   - links grabing is enabled, indeed if at least one of the below conditions is met:
-      - the specified URL is the same as the reference URL given by the specified receiver urlProcess,
-      - the specified URL is maximum level 2, relatively to the reference URL.
+   - links grabing is enabled, indeed if the specified depth is lower than the maximum depth of the job,
+   - the found URLs are referenced by <a> tags, or by <link> tags other than stylesheets (fetched as assets by the images extractor),
    - the found URLs are not already part of the crawled URLs nor processing URLs set of the specified receiver urlProcess,
-   - the found URLs have the same host value as the reference URL's one.
-- add found data (images) for the specified URL in the processing URLs set of the specified receiver urlProcess if:
-   - the found data have not been added for the specified URL yet,
-   - the found data are images that have the following extensions only: .png, .gif or .jpeg.
-*/
-func (urlProcess *UrlProcess) CrawlUrl(urlToCrawl *string) { 
-   // Reading URL content body, and leaving the function if an error is raised.
-   urlContent, err := http.Get(*urlToCrawl)
+   - the found URLs have the same host value as the reference URL's one,
+   - the found URLs are allowed by the robots.txt rules; they shall be recorded as skipped by robots.txt else.
+  The found URLs shall be added with a depth of one more than the specified depth, or keep their depth if already waiting with a lower one.
+- give each start tag, self-closing tag and <style> tag content to the extractors of the job, and add the records they return to the data of the
+  specified URL in the processing URLs set of the specified receiver urlProcess, grouped by extractor name.
+*/
+func (urlProcess *UrlProcess) CrawlUrl(ctx context.Context, urlToCrawl *string, depth int) { 
+   robotsAgent := urlProcess.Config.RobotsAgent
+
+   // Checking the robots.txt rules of the host before crawling the URL
+   parsedUrlToCrawl, err := ParseUrl(urlToCrawl)
    if err != nil {
-      fmt.Println("ERROR: Failed to crawl: ", *urlToCrawl)
+      urlProcess.fail(ctx, urlToCrawl, &UrlFailure{Class:FAILURE_INVALID_URL, Message:err.Error()})
       return
    }
-   urlBody := urlContent.Body
-   defer urlBody.Close()
+   robotsRules, err := GetRobots(ctx, urlProcess.client(), urlProcess.Config.Identity, parsedUrlToCrawl)
+   if err != nil {
+      urlProcess.fail(ctx, urlToCrawl, NewUrlFailure(err))
+      return
+   }
+   if !robotsRules.Allowed(robotsAgent, parsedUrlToCrawl) {
+      fmt.Println("Skipping URL disallowed by robots.txt: ", *urlToCrawl)
+      urlProcess.skipByRobots(*urlToCrawl)
+      return
+   }
+   hostDelay := urlProcess.Config.HostDelay
+   if crawlDelay := robotsRules.CrawlDelay(robotsAgent); crawlDelay > hostDelay {
+      hostDelay = crawlDelay
+   }
+   // Reading URL content body, from the shared fetch cache if enabled, and leaving the function if an error is raised.
+   // The page crawled by the last crawl of the seed set is requested again only if modified, bypassing the fetch cache.
+   // The whole body is read before parsing, so that the host slot of the page is released before fetching its stylesheets and images.
+   var fetchedPage *CachedPage
+   previousPage := urlProcess.Config.History.Previous(*urlToCrawl)
+   if (urlProcess.Config.FetchCacheTTL > 0) && (previousPage == nil) {
+      fetchedPage, err = GetCachedPage(ctx, *urlToCrawl, urlProcess.Config.Identity, urlProcess.Config.FetchCacheTTL, func() (*CachedPage, error) {
+         return urlProcess.fetchPage(ctx, urlToCrawl, parsedUrlToCrawl.Host, hostDelay, nil)
+      })
+      if (err == nil) && (urlProcess.client().Jar != nil) {
+         // Keeping the cookies set by the page, in case it was fetched by another job
+         urlProcess.client().Jar.SetCookies(fetchedPage.Url, (&http.Response{Header:fetchedPage.Header}).Cookies())
+      }
+   } else {
+      fetchedPage, err = urlProcess.fetchPage(ctx, urlToCrawl, parsedUrlToCrawl.Host, hostDelay, previousPage)
+   }
+   if err != nil {
+      urlProcess.fail(ctx, urlToCrawl, NewUrlFailure(err))
+      return
+   }
+   urlProcess.recordStatus(*urlToCrawl, fetchedPage.StatusCode)
+   if (fetchedPage.StatusCode == http.StatusNotModified) && (previousPage != nil) {
+      // Page not modified since the last crawl: reusing its links and data
+      urlProcess.reusePage(urlToCrawl, previousPage, depth, robotsRules)
+      return
+   }
+   if (fetchedPage.StatusCode < 200) || (fetchedPage.StatusCode >= 300) {
+      urlProcess.fail(ctx, urlToCrawl, NewHttpFailure(fetchedPage.StatusCode))
+      return
+   }
+   urlBody := bytes.NewReader(fetchedPage.Body)
+   finalUrl := fetchedPage.Url
+   header := fetchedPage.Header
 
-   refUrl := urlProcess.DomainUrl
+   // Resolving the found links and data against the final URL of the crawled page, after redirects
+   page := NewPage(urlProcess, finalUrl, depth)
 
-   collectLinksEnable := false
-   urlExtension := strings.SplitAfter(*urlToCrawl, refUrl.String())
-   // Collecting links if the crawling URL is the reference URL or if the URL level is maximum 2
-   if( (*urlToCrawl == refUrl.String()) || (len(strings.Split(urlExtension[len(urlExtension)-1], "/")) < LINKS_LEVEL) ) {
-      collectLinksEnable = true
-   }
+   // Collecting links if the crawling URL is not at the maximum depth yet
+   collectLinksEnable := (depth < urlProcess.Config.MaxDepth)
+   // Recording the page with its links for the later crawls
+   pageRecord := &PageRecord{ETag:header.Get("ETag"), LastModified:header.Get("Last-Modified")}
 
    // Looping on all tokens found in the crawled ULR
    urlTokenizer := html.NewTokenizer(urlBody)
@@ -89,74 +242,334 @@ func (urlProcess *UrlProcess) CrawlUrl(urlToCrawl *string) {
       tokenizeItem := urlTokenizer.Next()
       switch {
          case tokenizeItem == html.ErrorToken:
-            // Case where the current token means end of the URL-> ending the crawling task
+            // Case where the current token means end of the URL-> ending the crawling task, the page failing if its body could not be read
+            if err := urlTokenizer.Err(); err != io.EOF {
+               urlProcess.failPage(ctx, urlToCrawl, NewUrlFailure(err))
+               return
+            }
+            urlProcess.recordPage(urlToCrawl, pageRecord)
             return
-         case tokenizeItem == html.StartTagToken:
+         case (tokenizeItem == html.StartTagToken) || (tokenizeItem == html.SelfClosingTagToken):
             // Case where the current token is a html tag
             token := urlTokenizer.Token() 
-            if((token.Data == "a")||(token.Data == "link")) {
-               // Checking if the tag is an <a> or a <link> tag
-               if collectLinksEnable { 
-                  // Extracting the link if needed
-                  hasLink, link := getTokenValue(token, "href")
-                  if hasLink {
-                     // If exisiting, parsing the link to the absolute URL path
-                     parsedUrl, errParse := ParseUrl(&link)
-                     if(errParse == nil){
-                        linkAbs := refUrl.ResolveReference(parsedUrl)
-                        // Checking that the parsed URL link is not in the crawling URLs set neither processing URLs set from the receiver specified URL process
-                        crawledUrls := urlProcess.CrawledUrls
-                        processingUrls := urlProcess.ProcessingUrls
-                        processingUrls.Lock()
-                        _, alreadyProcessing := processingUrls.UrlsData[linkAbs.String()]
-                        processingUrls.Unlock()
-                        crawledUrls.Lock()
-                        _, alreadyCrawled := crawledUrls.UrlsData[linkAbs.String()]
-                        crawledUrls.Unlock()
-                        if (!alreadyCrawled && !alreadyProcessing) {
-                           // If the parsed URL link never seen yet, checking if the host of the parsed URL is the same as the one of the reference URL
-                           if((linkAbs.Host == refUrl.Host)){ 
-                              // Adding the parsed URL link as a new key in the waiting URLs set from the receiver specified URL process (no need to have a value associated to this key)
-                              waitingUrls := urlProcess.WaitingUrls
-                              waitingUrls.Lock()
-                              waitingUrls.Urls[linkAbs.String()] = "" 
-                              waitingUrls.Unlock()     
-                           }  
-                        }
-                     }
-                  }    
-               }
-            } else if (token.Data == "img") {
-               // Checking if the tag is an <img> tag
-               hasImg, img := getTokenValue(token, "src")
-               // Extracting the image path
-               if hasImg {
-                  // If exisiting, parsing the image path to the absolute URL path
-                  parsedUrl, errParse := ParseUrl(&img)
-                  if(errParse == nil){
-                     imgAbs := refUrl.ResolveReference(parsedUrl)
-                     // Checking if image extension is .png, .gif or .jpeg
-                     imgAbsSplit := strings.Split(imgAbs.String(), ".")
-                     imgAbsExtension := imgAbsSplit[len(imgAbsSplit)-1] 
-                     if((imgAbsExtension == "png") || (imgAbsExtension == "gif") || (imgAbsExtension == "jpeg")){
-                        // Adding the parsed URL link to the specified crawled URLs
-                        processingUrls := urlProcess.ProcessingUrls
-                        processingUrls.Lock()
-                        if _, alreadySeen := processingUrls.UrlsData[*urlToCrawl][imgAbs.String()]; !alreadySeen {
-                           // Adding the parsed URL link if not seen yet during the on-going crawling as a new key in the processing URLs set from the receiver specified URL process (no need to have a value associated to this key)
-                           processingUrls.UrlsData[*urlToCrawl][imgAbs.String()] = ""    
-                        }
-                        processingUrls.Unlock()          
+            page.trackToken(token)
+            if(((token.Data == "a") || (token.Data == "link")) && !isStylesheetLink(getTokenAttribute(token, "rel"))) {
+               // Checking if the tag is an <a> or a <link> tag, not to a stylesheet, and extracting the link if existing
+               hasLink, link := getTokenValue(token, "href")
+               if hasLink {
+                  // If exisiting, parsing the link to the absolute URL path
+                  linkAbs, resolved := page.Resolve(link)
+                  if(resolved){
+                     pageRecord.Links = append(pageRecord.Links, linkAbs.String())
+                     if collectLinksEnable { 
+                        urlProcess.followLink(linkAbs, depth, robotsRules)
                      }
                   }
                }
             }
+            urlProcess.extract(ctx, urlToCrawl, page, token)
+         case tokenizeItem == html.EndTagToken:
+            // Case where the current token is a closing html tag
+            page.trackToken(urlTokenizer.Token())
+         case (tokenizeItem == html.TextToken) && page.Inside("style"):
+            // Case where the current token is the content of a <style> tag
+            urlProcess.extract(ctx, urlToCrawl, page, urlTokenizer.Token())
       } 
 
    }
 
 }
 
+// Helper function to add a link found in a page crawled at the specified depth to the waiting URLs set, one hop further, if the following conditions are met:
+// not part of the crawled URLs nor processing URLs set, same host as the reference URL, allowed by the robots.txt rules (recorded as skipped else)
+func (urlProcess *UrlProcess) followLink(linkAbs *url.URL, depth int, robotsRules *RobotsRules) {
+   // Checking that the parsed URL link is not in the crawling URLs set neither processing URLs set from the receiver specified URL process
+   crawledUrls := urlProcess.CrawledUrls
+   processingUrls := urlProcess.ProcessingUrls
+   processingUrls.Lock()
+   _, alreadyProcessing := processingUrls.UrlsData[linkAbs.String()]
+   processingUrls.Unlock()
+   crawledUrls.Lock()
+   _, alreadyCrawled := crawledUrls.UrlsData[linkAbs.String()]
+   crawledUrls.Unlock()
+   if (alreadyCrawled || alreadyProcessing) {
+      return
+   }
+   // If the parsed URL link never seen yet, checking if the host of the parsed URL is the same as the one of the reference URL
+   if(linkAbs.Host != urlProcess.DomainUrl.Host){ 
+      return
+   }
+   if !robotsRules.Allowed(urlProcess.Config.RobotsAgent, linkAbs) {
+      // Not queuing the parsed URL link disallowed by robots.txt
+      urlProcess.skipByRobots(linkAbs.String())
+      return
+   }
+   // Adding the parsed URL link as a new key in the waiting URLs set from the receiver specified URL process, one hop further than the crawled URL
+   waitingUrls := urlProcess.WaitingUrls
+   waitingUrls.Lock()
+   waitingInfo, alreadyWaiting := waitingUrls.Urls[linkAbs.String()]
+   if !alreadyWaiting {
+      urlProcess.PushWaitingUrl(linkAbs.String(), &UrlInfo{Depth:depth + 1})
+   } else if waitingInfo.Depth > depth + 1 {
+      // Keeping the attempts of a URL waiting to be retried
+      urlProcess.PushWaitingUrl(linkAbs.String(), &UrlInfo{Depth:depth + 1, Attempts:waitingInfo.Attempts, NotBefore:waitingInfo.NotBefore})
+   }
+   waitingUrls.Unlock()
+   if !alreadyWaiting {
+      urlProcess.notifyPushed()
+   }
+}
+
+// Helper function to record the specified crawled page in the crawl history, with the data extracted from it
+func (urlProcess *UrlProcess) recordPage(urlToCrawl *string, pageRecord *PageRecord) {
+   if urlProcess.Config.History == nil {
+      return
+   }
+   processingUrls := urlProcess.ProcessingUrls
+   processingUrls.Lock()
+   pageRecord.Data = copyPageData(processingUrls.UrlsData[*urlToCrawl])
+   processingUrls.Unlock()
+   urlProcess.Config.History.Record(*urlToCrawl, pageRecord)
+}
+
+// Helper function to reuse the links and data of the specified page, not modified since the last crawl, for the specified URL crawled at the specified depth
+func (urlProcess *UrlProcess) reusePage(urlToCrawl *string, previousPage *PageRecord, depth int, robotsRules *RobotsRules) {
+   fmt.Println("Not modified since the last crawl: ", *urlToCrawl)
+   processingUrls := urlProcess.ProcessingUrls
+   processingUrls.Lock()
+   processingUrls.UrlsData[*urlToCrawl] = copyPageData(previousPage.Data)
+   processingUrls.Unlock()
+   if depth < urlProcess.Config.MaxDepth {
+      for _, link := range previousPage.Links {
+         if linkAbs, err := url.Parse(link); err == nil {
+            urlProcess.followLink(linkAbs, depth, robotsRules)
+         }
+      }
+   }
+   urlProcess.Lock()
+   urlProcess.Unchanged[*urlToCrawl] = true
+   urlProcess.Unlock()
+   urlProcess.Config.History.Record(*urlToCrawl, previousPage)
+}
+
+// Helper function to send the GET request of the specified URL, bound to the specified context, conditional if a previous page is specified
+func (urlProcess *UrlProcess) get(ctx context.Context, urlToGet *string, previousPage *PageRecord) (*http.Response, error) {
+   request, err := http.NewRequestWithContext(ctx, http.MethodGet, *urlToGet, nil)
+   if err != nil {
+      return nil, err
+   }
+   setValidators(request, previousPage)
+   return urlProcess.client().Do(request)
+}
+
+// Helper function to fetch the whole page of the specified URL through the shared host limiter, only if modified since the specified previous crawl
+// if any; the host slot is released once the page is fetched
+func (urlProcess *UrlProcess) fetchPage(ctx context.Context, urlToFetch *string, host string, hostDelay time.Duration, previousPage *PageRecord) (*CachedPage, error) {
+   release, err := SharedHostLimiter.Acquire(ctx, host, hostDelay, urlProcess.Config.HostMaxConcurrent)
+   if err != nil {
+      return nil, err
+   }
+   defer release()
+   response, err := urlProcess.get(ctx, urlToFetch, previousPage)
+   if err != nil {
+      return nil, err
+   }
+   defer response.Body.Close()
+   if (urlProcess.Config.MaxBodySize > 0) && (response.ContentLength > urlProcess.Config.MaxBodySize) {
+      return nil, errBodyTooLarge
+   }
+   body, err := ioutil.ReadAll(urlProcess.limitBody(response.Body))
+   urlProcess.countDownloaded(len(body))
+   if err != nil {
+      return nil, err
+   }
+   return &CachedPage{Url:response.Request.URL, StatusCode:response.StatusCode, Header:response.Header, Body:body}, nil
+}
+
+// Helper function to wake up the workers waiting for URLs to crawl, once URLs pushed in the waiting URLs set; never called with the set locked
+func (urlProcess *UrlProcess) notifyPushed() {
+   if urlProcess.Config.Notify != nil {
+      urlProcess.Config.Notify()
+   }
+}
+
+// Helper function to give a token of the crawled page to the extractors of the job, and add the records they return to the data of the crawled URL
+func (urlProcess *UrlProcess) extract(ctx context.Context, urlToCrawl *string, page *Page, token html.Token) {
+   for _, extractor := range urlProcess.Config.Extractors {
+      if records := extractor.Extract(ctx, page, token); len(records) > 0 {
+         urlProcess.addRecords(urlToCrawl, extractor.Name(), records)
+      }
+   }
+}
+
+/* Adding records to the crawled URL data.
+This method shall add the specified records of the specified extractor to the data of the specified crawled URL in the processing URLs set of the
+specified receiver urlProcess. The detail of a record (srcset descriptor for instance) shall be recorded as the value associated to the record value,
+the details being listed once each, comma separated, when a record value is found several times.
+*/
+func (urlProcess *UrlProcess) addRecords(urlToCrawl *string, extractorName string, records []Record) {
+   processingUrls := urlProcess.ProcessingUrls
+   processingUrls.Lock()
+   defer processingUrls.Unlock()
+   urlData := processingUrls.UrlsData[*urlToCrawl]
+   extractorData, existing := urlData[extractorName]
+   if !existing {
+      extractorData = map[string]string{}
+      urlData[extractorName] = extractorData
+   }
+
+   RecordsLoop:
+   for _, record := range records {
+      details, alreadySeen := extractorData[record.Value]
+      if !alreadySeen || (details == "") {
+         extractorData[record.Value] = record.Detail
+      } else if record.Detail != "" {
+         for _, seenDetail := range strings.Split(details, DETAILS_SEPARATOR) {
+            if seenDetail == record.Detail {
+               continue RecordsLoop
+            }
+         }
+         extractorData[record.Value] = details + DETAILS_SEPARATOR + record.Detail
+      }
+   }
+}
+
+/* Splitting record details.
+This method shall return the list of the details recorded for a record value (see addRecords), empty if none.
+*/
+func SplitDetails(details string) []string {
+   if details == "" {
+      return []string{}
+   }
+   return strings.Split(details, DETAILS_SEPARATOR)
+}
+
+// Helper function to record a URL which could not be crawled, with its failure, unless its crawling has been aborted by the specified context
+func (urlProcess *UrlProcess) fail(ctx context.Context, failedUrl *string, failure *UrlFailure) {
+   if ctx.Err() != nil {
+      return
+   }
+   fmt.Println("ERROR: Failed to crawl (" + failure.Class + "): ", *failedUrl, failure.Message)
+   urlProcess.Lock()
+   urlProcess.Failed[*failedUrl] = failure
+   urlProcess.Unlock()
+}
+
+// Helper function to record a page which body could not be read as failed, dropping the data partially extracted from it
+func (urlProcess *UrlProcess) failPage(ctx context.Context, failedUrl *string, failure *UrlFailure) {
+   processingUrls := urlProcess.ProcessingUrls
+   processingUrls.Lock()
+   if _, processing := processingUrls.UrlsData[*failedUrl]; processing {
+      processingUrls.UrlsData[*failedUrl] = PageData{}
+   }
+   processingUrls.Unlock()
+   urlProcess.fail(ctx, failedUrl, failure)
+}
+
+/* Checking seed failure.
+This method shall return whether the specified URL of the specified receiver urlProcess (its seed) could not be crawled.
+*/
+func (urlProcess *UrlProcess) SeedFailed() bool {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   _, failed := urlProcess.Failed[urlProcess.Seed]
+   return failed
+}
+
+/* Getting the failed URLs.
+This method shall return a copy of the failures (values) of the URLs (keys) which could not be crawled for the specified receiver urlProcess.
+*/
+func (urlProcess *UrlProcess) Failures() map[string]*UrlFailure {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   failures := make(map[string]*UrlFailure, len(urlProcess.Failed))
+   for failedUrl, failure := range urlProcess.Failed {
+      failures[failedUrl] = failure
+   }
+   return failures
+}
+
+/* Getting the HTTP statuses.
+This method shall return a copy of the HTTP status code of the last response (values) of the URLs (keys) which got one for the specified receiver
+urlProcess, whether crawled successfully or not.
+*/
+func (urlProcess *UrlProcess) HttpStatuses() map[string]int {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   statuses := make(map[string]int, len(urlProcess.Statuses))
+   for fetchedUrl, statusCode := range urlProcess.Statuses {
+      statuses[fetchedUrl] = statusCode
+   }
+   return statuses
+}
+
+/* Counting failed URLs.
+This method shall return the number of distinct URLs which could not be crawled for the specified receiver urlProcess.
+*/
+func (urlProcess *UrlProcess) CountFailed() int {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   return len(urlProcess.Failed)
+}
+
+/* Counting downloaded bytes.
+This method shall return the number of bytes downloaded when crawling the URLs of the specified receiver urlProcess, pages and assets.
+*/
+func (urlProcess *UrlProcess) CountDownloaded() int64 {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   return urlProcess.Downloaded
+}
+
+// Helper function to record bytes downloaded when crawling
+func (urlProcess *UrlProcess) countDownloaded(length int) {
+   urlProcess.Lock()
+   urlProcess.Downloaded += int64(length)
+   urlProcess.Unlock()
+}
+
+/* Getting a URL failure.
+This method shall return the failure of the specified URL of the specified receiver urlProcess, or false if it did not fail.
+*/
+func (urlProcess *UrlProcess) Failure(failedUrl string) (*UrlFailure, bool) {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   failure, failed := urlProcess.Failed[failedUrl]
+   return failure, failed
+}
+
+// Helper function to record the HTTP status code of the response of a URL
+func (urlProcess *UrlProcess) recordStatus(fetchedUrl string, statusCode int) {
+   urlProcess.Lock()
+   urlProcess.Statuses[fetchedUrl] = statusCode
+   urlProcess.Unlock()
+}
+
+// Helper function to record a URL skipped because disallowed by robots.txt
+func (urlProcess *UrlProcess) skipByRobots(skippedUrl string) {
+   urlProcess.Lock()
+   urlProcess.RobotsSkipped[skippedUrl] = true
+   urlProcess.Unlock()
+}
+
+/* Counting URLs skipped by robots.txt.
+This method shall return the number of distinct URLs skipped because disallowed by robots.txt rules for the specified receiver urlProcess.
+*/
+func (urlProcess *UrlProcess) CountRobotsSkipped() int {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   return len(urlProcess.RobotsSkipped)
+}
+
+/* Counting unchanged URLs.
+This method shall return the number of distinct URLs not modified since the last crawl of the seed set for the specified receiver urlProcess.
+*/
+func (urlProcess *UrlProcess) CountUnchanged() int {
+   urlProcess.Lock()
+   defer urlProcess.Unlock()
+   return len(urlProcess.Unchanged)
+}
+
 /* URL parsing.
 This method shall parse the specified URL and return the parsed URL with the associated error.
 */
@@ -165,25 +578,201 @@ func ParseUrl(urlToParse *string) (*url.URL, error) {
    return urlParsed, err
 }
 
+/* Pushing a waiting URL.
+This method shall add the specified URL, with the specified info replacing its previous one if any, to the waiting URLs set of the specified receiver
+urlProcess and to the job frontier. A URL not to be crawled before a time to come shall only be pushed in the job frontier at that time, if still
+waiting with the same info. It shall be called with the waiting URLs set locked.
+*/
+func (urlProcess *UrlProcess) PushWaitingUrl(waitingUrl string, info *UrlInfo) {
+   urlProcess.WaitingUrls.Urls[waitingUrl] = info
+   if info.NotBefore != nil {
+      if delay := time.Until(*info.NotBefore); delay > 0 {
+         time.AfterFunc(delay, func() { urlProcess.pushDueUrl(waitingUrl, info) })
+         return
+      }
+   }
+   urlProcess.Frontier.Push(urlProcess.Seed, waitingUrl, info)
+}
+
+// Helper function to push in the job frontier a URL which time to be crawled has come, unless not waiting with the specified info anymore
+func (urlProcess *UrlProcess) pushDueUrl(waitingUrl string, info *UrlInfo) {
+   waitingUrls := urlProcess.WaitingUrls
+   waitingUrls.Lock()
+   due := (waitingUrls.Urls[waitingUrl] == info)
+   if due {
+      urlProcess.Frontier.Push(urlProcess.Seed, waitingUrl, info)
+   }
+   waitingUrls.Unlock()
+   if due {
+      urlProcess.notifyPushed()
+   }
+}
+
+/* Taking a waiting URL.
+This method shall remove the specified URL, popped with the specified info from the job frontier, from the waiting URLs set of the specified receiver
+urlProcess, and add it to the processing URLs set, so that it is always pending. It shall return false, leaving the sets unchanged, for a stale
+frontier entry: URL not waiting anymore, or pushed again since with another info.
+*/
+func (urlProcess *UrlProcess) TakeWaitingUrl(waitingUrl string, info *UrlInfo) bool {
+   waitingUrls := urlProcess.WaitingUrls
+   processingUrls := urlProcess.ProcessingUrls
+   waitingUrls.Lock()
+   defer waitingUrls.Unlock()
+   if waitingUrls.Urls[waitingUrl] != info {
+      return false
+   }
+   processingUrls.Lock()
+   delete(waitingUrls.Urls, waitingUrl)
+   processingUrls.UrlsData[waitingUrl] = PageData{}
+   processingUrls.UrlsInfo[waitingUrl] = info
+   processingUrls.Unlock()
+   return true
+}
+
 /* UrlProcess initialization.
 This method shall initialize a UrlProcess by:
-- assigning the parsed specified URL to the DomainUrl parameter,
-- initializing the waitingUrls parameter with the specified URL only (no data): will be used as a set to store all the URLs related to the specified URL, waiting to be crawled,
+- assigning the specified crawling settings to the Config parameter, and the specified frontier of the job to the Frontier parameter,
+- assigning the specified URL to the Seed parameter, and the parsed specified URL to the DomainUrl parameter,
+- initializing the waitingUrls parameter with the specified URL only, at depth 0, pushed in the frontier: will be used as a set to store all the URLs related to the specified URL, waiting to be crawled,
 - initializing the processingUrls parameter empty (no URL nor data): will be used as a set to store all the URLs related to the specified URL, being crawled,
-- initializing the crawledUrls parameter empty (no URL nor data): will be used as a set to store all the URLs related to the specified URL, already crawled.
+- initializing the crawledUrls parameter empty (no URL nor data): will be used as a set to store all the URLs related to the specified URL, already crawled,
+- initializing the robotsSkipped, unchanged, failed and statuses parameters empty.
 */
-func (urlProcess *UrlProcess) InitUrlProcess(urlToParse *string) {
-   // Assigning the parsed specified URL
+func (urlProcess *UrlProcess) InitUrlProcess(urlToParse *string, config *CrawlConfig, frontier Frontier) {
+   urlProcess.Config = config
+   urlProcess.Frontier = frontier
+   // Assigning the specified URL and the parsed specified URL
+   urlProcess.Seed = *urlToParse
    parsedUrl, _ := ParseUrl(urlToParse)
    urlProcess.DomainUrl = parsedUrl
    // Initializing the waitingUrls 
-   waitingUrls := &Urls{Urls:map[string]string{*urlToParse:""}}
+   waitingUrls := &Urls{Urls:map[string]*UrlInfo{}}
    urlProcess.WaitingUrls = waitingUrls
+   urlProcess.PushWaitingUrl(*urlToParse, &UrlInfo{Depth:0})
    // Initializing the processingUrls 
-   processingUrls := &MapUrlsData{UrlsData:map[string]map[string]string{}}
+   processingUrls := &MapUrlsData{UrlsData:map[string]PageData{}, UrlsInfo:map[string]*UrlInfo{}}
    urlProcess.ProcessingUrls = processingUrls
    // Initializing the crawledUrls 
-   crawledUrls := &MapUrlsData{UrlsData:map[string]map[string]string{}}
+   crawledUrls := &MapUrlsData{UrlsData:map[string]PageData{}, UrlsInfo:map[string]*UrlInfo{}}
    urlProcess.CrawledUrls = crawledUrls
+   // Initializing the robotsSkipped, unchanged, failed and statuses
+   urlProcess.RobotsSkipped = map[string]bool{}
+   urlProcess.Unchanged = map[string]bool{}
+   urlProcess.Failed = map[string]*UrlFailure{}
+   urlProcess.Statuses = map[string]int{}
+}
+
+// Helper function to copy URLs info sets
+func copyUrlsInfo(urlsInfo map[string]*UrlInfo) map[string]*UrlInfo {
+   urlsInfoCopy := make(map[string]*UrlInfo, len(urlsInfo))
+   for url, info := range urlsInfo {
+      infoCopy := UrlInfo{}
+      if info != nil {
+         infoCopy = *info
+      }
+      urlsInfoCopy[url] = &infoCopy
+   }
+   return urlsInfoCopy
+}
+
+// Helper function to deep copy the data of a page
+func copyPageData(pageData PageData) PageData {
+   pageDataCopy := make(PageData, len(pageData))
+   for extractorName, data := range pageData {
+      dataCopy := make(map[string]string, len(data))
+      for key, val := range data {
+         dataCopy[key] = val
+      }
+      pageDataCopy[extractorName] = dataCopy
+   }
+   return pageDataCopy
+}
+
+// Helper function to deep copy URLs data sets
+func copyUrlsData(urlsData map[string]PageData) map[string]PageData {
+   urlsDataCopy := make(map[string]PageData, len(urlsData))
+   for url, pageData := range urlsData {
+      urlsDataCopy[url] = copyPageData(pageData)
+   }
+   return urlsDataCopy
+}
+
+/* UrlProcess checkpoint.
+This method shall return a consistent copy of the waiting URLs, processing URLs and crawled URLs sets of the specified receiver urlProcess.
+*/
+func (urlProcess *UrlProcess) Checkpoint() *UrlProcessCheckpoint {
+   waitingUrls := urlProcess.WaitingUrls
+   processingUrls := urlProcess.ProcessingUrls
+   crawledUrls := urlProcess.CrawledUrls
+   waitingUrls.Lock()
+   processingUrls.Lock()
+   crawledUrls.Lock()
+   defer waitingUrls.Unlock()
+   defer processingUrls.Unlock()
+   defer crawledUrls.Unlock()
+
+   checkpoint := &UrlProcessCheckpoint{}
+   checkpoint.WaitingUrls = copyUrlsInfo(waitingUrls.Urls)
+   checkpoint.ProcessingUrls = copyUrlsInfo(processingUrls.UrlsInfo)
+   checkpoint.CrawledUrls = copyUrlsData(crawledUrls.UrlsData)
+   urlProcess.Lock()
+   for skippedUrl, _ := range urlProcess.RobotsSkipped {
+      checkpoint.RobotsSkipped = append(checkpoint.RobotsSkipped, skippedUrl)
+   }
+   for unchangedUrl, _ := range urlProcess.Unchanged {
+      checkpoint.Unchanged = append(checkpoint.Unchanged, unchangedUrl)
+   }
+   checkpoint.Failed = make(map[string]*UrlFailure, len(urlProcess.Failed))
+   for failedUrl, failure := range urlProcess.Failed {
+      checkpoint.Failed[failedUrl] = failure
+   }
+   checkpoint.Statuses = make(map[string]int, len(urlProcess.Statuses))
+   for fetchedUrl, statusCode := range urlProcess.Statuses {
+      checkpoint.Statuses[fetchedUrl] = statusCode
+   }
+   checkpoint.Downloaded = urlProcess.Downloaded
+   urlProcess.Unlock()
+   return checkpoint
 }
 
+/* UrlProcess restoration.
+This method shall restore the waiting URLs and crawled URLs sets, the URLs skipped by robots.txt, unchanged or failed, the HTTP statuses, and the number of bytes downloaded,
+of the specified receiver urlProcess from the specified checkpoint.
+The URLs which were being crawled when the checkpoint was taken shall be put back in the waiting URLs set with their info, their partial data being dropped,
+and the processing URLs set shall be empty. The waiting URLs shall be pushed in the job frontier in the order of their URL.
+*/
+func (urlProcess *UrlProcess) RestoreUrlProcess(checkpoint *UrlProcessCheckpoint) {
+   restoredUrls := copyUrlsInfo(checkpoint.WaitingUrls)
+   for url, info := range copyUrlsInfo(checkpoint.ProcessingUrls) {
+      restoredUrls[url] = info
+   }
+   waitingUrls := &Urls{Urls:map[string]*UrlInfo{}}
+   urlProcess.WaitingUrls = waitingUrls
+   sortedUrls := make([]string, 0, len(restoredUrls))
+   for url := range restoredUrls {
+      sortedUrls = append(sortedUrls, url)
+   }
+   sort.Strings(sortedUrls)
+   for _, url := range sortedUrls {
+      urlProcess.PushWaitingUrl(url, restoredUrls[url])
+   }
+   urlProcess.ProcessingUrls = &MapUrlsData{UrlsData:map[string]PageData{}, UrlsInfo:map[string]*UrlInfo{}}
+   urlProcess.CrawledUrls = &MapUrlsData{UrlsData:copyUrlsData(checkpoint.CrawledUrls), UrlsInfo:map[string]*UrlInfo{}}
+   urlProcess.RobotsSkipped = map[string]bool{}
+   for _, skippedUrl := range checkpoint.RobotsSkipped {
+      urlProcess.RobotsSkipped[skippedUrl] = true
+   }
+   urlProcess.Unchanged = map[string]bool{}
+   for _, unchangedUrl := range checkpoint.Unchanged {
+      urlProcess.Unchanged[unchangedUrl] = true
+   }
+   urlProcess.Failed = map[string]*UrlFailure{}
+   for failedUrl, failure := range checkpoint.Failed {
+      urlProcess.Failed[failedUrl] = failure
+   }
+   urlProcess.Statuses = map[string]int{}
+   for fetchedUrl, statusCode := range checkpoint.Statuses {
+      urlProcess.Statuses[fetchedUrl] = statusCode
+   }
+   urlProcess.Downloaded = checkpoint.Downloaded
+}
@@ -0,0 +1,212 @@
+package UrlCrawling
+
+import (
+   "context"
+   "encoding/json"
+   "net/http"
+   "net/http/httptest"
+   "reflect"
+   "sort"
+   "testing"
+   "time"
+)
+
+// Helper function to serve the specified contents (values) by path (keys), robots.txt being missing
+func newTestServer(t *testing.T, contents map[string]string) *httptest.Server {
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      content, existing := contents[r.URL.Path]
+      if !existing {
+         http.NotFound(w, r)
+         return
+      }
+      w.Write([]byte(content))
+   }))
+   t.Cleanup(server.Close)
+   return server
+}
+
+// Helper function to crawl the specified seed with the specified settings, at most one request at a time on its host, and return the records of the
+// images extracted from it; failing if the crawl does not end in time
+func crawlTestSeed(t *testing.T, seed string, config *CrawlConfig) []string {
+   config.HostMaxConcurrent = 1
+   config.Extractors, _ = NewExtractors(nil)
+   frontier, _ := NewFrontier("")
+   urlProcess := &UrlProcess{}
+   urlProcess.InitUrlProcess(&seed, config, frontier)
+   urlProcess.TakeWaitingUrl(seed, urlProcess.WaitingUrls.Urls[seed])
+
+   ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
+   defer cancel()
+   done := make(chan struct{})
+   go func() {
+      urlProcess.CrawlUrl(ctx, &seed, 0)
+      close(done)
+   }()
+   select {
+      case <-done:
+      case <-ctx.Done():
+         t.Fatal("crawl of " + seed + " not over in time")
+   }
+
+   images := []string{}
+   urlProcess.ProcessingUrls.Lock()
+   for image := range urlProcess.ProcessingUrls.UrlsData[seed][IMAGES_EXTRACTOR] {
+      images = append(images, image)
+   }
+   urlProcess.ProcessingUrls.Unlock()
+   sort.Strings(images)
+   return images
+}
+
+func TestCrawlUrlStylesheetSameHost(t *testing.T) {
+   server := newTestServer(t, map[string]string{
+      "/": `<html><head><link rel="stylesheet" href="/style.css"></head></html>`,
+      "/style.css": `@import "more.css"; body { background: url(bg.png) }`,
+      "/more.css": `div { background: url('/more.png') }`,
+   })
+   images := crawlTestSeed(t, server.URL + "/", &CrawlConfig{ImageTypes:NewImageTypes([]string{"png"})})
+   want := []string{server.URL + "/bg.png", server.URL + "/more.png"}
+   if (len(images) != len(want)) || (images[0] != want[0]) || (images[1] != want[1]) {
+      t.Errorf("images %v, want %v", images, want)
+   }
+}
+
+func TestCrawlUrlUnreachableHost(t *testing.T) {
+   server := newTestServer(t, map[string]string{})
+   seed := server.URL + "/"
+   server.Close()
+
+   config := &CrawlConfig{ImageTypes:NewImageTypes([]string{"png"})}
+   config.HostMaxConcurrent = 1
+   config.Extractors, _ = NewExtractors(nil)
+   frontier, _ := NewFrontier("")
+   urlProcess := &UrlProcess{}
+   urlProcess.InitUrlProcess(&seed, config, frontier)
+   urlProcess.TakeWaitingUrl(seed, urlProcess.WaitingUrls.Urls[seed])
+   ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
+   defer cancel()
+   urlProcess.CrawlUrl(ctx, &seed, 0)
+
+   // The seed failing with a classified error, instead of being skipped by robots.txt
+   if len(urlProcess.RobotsSkipped) != 0 {
+      t.Errorf("seed of an unreachable host skipped by robots.txt: %v", urlProcess.RobotsSkipped)
+   }
+   failure := urlProcess.Failed[seed]
+   if (failure == nil) || (failure.Class != FAILURE_CONNECTION) {
+      t.Errorf("seed of an unreachable host failure %+v, want a %s failure", failure, FAILURE_CONNECTION)
+   }
+}
+
+func TestCrawlUrlHttpStatuses(t *testing.T) {
+   server := newTestServer(t, map[string]string{"/": "<html></html>"})
+   tests := []struct {
+      path string
+      status int
+      failed bool
+   }{
+      {"/", http.StatusOK, false},
+      {"/missing", http.StatusNotFound, true},
+   }
+   for _, test := range tests {
+      seed := server.URL + test.path
+      config := &CrawlConfig{HostMaxConcurrent:1}
+      config.Extractors, _ = NewExtractors(nil)
+      frontier, _ := NewFrontier("")
+      urlProcess := &UrlProcess{}
+      urlProcess.InitUrlProcess(&seed, config, frontier)
+      urlProcess.TakeWaitingUrl(seed, urlProcess.WaitingUrls.Urls[seed])
+      urlProcess.CrawlUrl(context.Background(), &seed, 0)
+
+      if status := urlProcess.HttpStatuses()[seed]; status != test.status {
+         t.Errorf("%s: HTTP status %d, want %d", test.path, status, test.status)
+      }
+      if _, failed := urlProcess.Failure(seed); failed != test.failed {
+         t.Errorf("%s: failed %v, want %v", test.path, failed, test.failed)
+      }
+   }
+}
+
+func TestCrawlUrlDetectImageSameHost(t *testing.T) {
+   server := newTestServer(t, map[string]string{
+      "/": `<html><body><img src="/photo"><img src="/page"></body></html>`,
+      "/photo": "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR",
+      "/page": "<html></html>",
+   })
+   images := crawlTestSeed(t, server.URL + "/", &CrawlConfig{ImageTypes:NewImageTypes([]string{"png"}), VerifyImageTypes:true})
+   if (len(images) != 1) || (images[0] != server.URL + "/photo") {
+      t.Errorf("images %v, want %v", images, []string{server.URL + "/photo"})
+   }
+}
+
+func TestCheckpointRestore(t *testing.T) {
+   seed := "http://example.com/"
+   frontier, _ := NewFrontier("")
+   urlProcess := &UrlProcess{}
+   urlProcess.InitUrlProcess(&seed, &CrawlConfig{}, frontier)
+
+   // Seed crawled, one page being crawled with partial data, one waiting, and one URL of each other kind
+   urlProcess.TakeWaitingUrl(seed, urlProcess.WaitingUrls.Urls[seed])
+   urlProcess.ProcessingUrls.UrlsData[seed] = PageData{IMAGES_EXTRACTOR:{"http://example.com/a.png":"1x"}}
+   urlProcess.CrawledUrls.UrlsData[seed] = urlProcess.ProcessingUrls.UrlsData[seed]
+   delete(urlProcess.ProcessingUrls.UrlsData, seed)
+   delete(urlProcess.ProcessingUrls.UrlsInfo, seed)
+   processing := "http://example.com/processing"
+   waiting := "http://example.com/waiting"
+   urlProcess.WaitingUrls.Lock()
+   urlProcess.PushWaitingUrl(processing, &UrlInfo{Depth:1, Attempts:1})
+   urlProcess.PushWaitingUrl(waiting, &UrlInfo{Depth:2, Priority:0.5})
+   urlProcess.WaitingUrls.Unlock()
+   urlProcess.TakeWaitingUrl(processing, urlProcess.WaitingUrls.Urls[processing])
+   urlProcess.ProcessingUrls.UrlsData[processing] = PageData{IMAGES_EXTRACTOR:{"http://example.com/partial.png":""}}
+   urlProcess.skipByRobots("http://example.com/private")
+   urlProcess.Unchanged["http://example.com/unchanged"] = true
+   urlProcess.Failed["http://example.com/failed"] = NewHttpFailure(404)
+   urlProcess.recordStatus(seed, 200)
+   urlProcess.Downloaded = 1234
+
+   // Checkpoint saved and reloaded as JSON, as done by the job store
+   encoded, err := json.Marshal(urlProcess.Checkpoint())
+   if err != nil {
+      t.Fatal(err)
+   }
+   checkpoint := &UrlProcessCheckpoint{}
+   if err := json.Unmarshal(encoded, checkpoint); err != nil {
+      t.Fatal(err)
+   }
+   restoredFrontier, _ := NewFrontier("")
+   restored := &UrlProcess{}
+   restored.InitUrlProcess(&seed, &CrawlConfig{}, restoredFrontier)
+   restoredFrontier.Pop()
+   restored.RestoreUrlProcess(checkpoint)
+
+   // The URL being crawled back to waiting with its info, without its partial data
+   wantWaiting := map[string]*UrlInfo{processing:{Depth:1, Attempts:1}, waiting:{Depth:2, Priority:0.5}}
+   if !reflect.DeepEqual(restored.WaitingUrls.Urls, wantWaiting) {
+      t.Errorf("restored waiting URLs %v, want %v", restored.WaitingUrls.Urls, wantWaiting)
+   }
+   if (len(restored.ProcessingUrls.UrlsData) != 0) || (len(restored.ProcessingUrls.UrlsInfo) != 0) {
+      t.Errorf("restored processing URLs %v, want none", restored.ProcessingUrls.UrlsData)
+   }
+   popped := []string{}
+   for {
+      _, url, info, available := restoredFrontier.Pop()
+      if !available {
+         break
+      }
+      if restored.TakeWaitingUrl(url, info) {
+         popped = append(popped, url)
+      }
+   }
+   if !reflect.DeepEqual(popped, []string{processing, waiting}) {
+      t.Errorf("restored URLs popped from the frontier %v, want %v", popped, []string{processing, waiting})
+   }
+
+   if !reflect.DeepEqual(restored.CrawledUrls.UrlsData, urlProcess.CrawledUrls.UrlsData) {
+      t.Errorf("restored crawled URLs %v, want %v", restored.CrawledUrls.UrlsData, urlProcess.CrawledUrls.UrlsData)
+   }
+   if !reflect.DeepEqual(restored.RobotsSkipped, urlProcess.RobotsSkipped) || !reflect.DeepEqual(restored.Unchanged, urlProcess.Unchanged) ||
+      !reflect.DeepEqual(restored.Failed, urlProcess.Failed) || !reflect.DeepEqual(restored.Statuses, urlProcess.Statuses) ||
+      (restored.Downloaded != urlProcess.Downloaded) {
+      t.Errorf("restored skipped, unchanged, failed, statuses or downloaded differ: %+v", checkpoint)
+   }
+}
@@ -5,6 +5,14 @@ import (
    "net/http"
 )
 
+/* Encoded JSON HTTP response: the JSON content, or the error raised by the transformation */
+type JsonResponse struct {
+   content []byte
+   err error
+}
+
+
+
 /* Removing duplicates from a slice of string.
 This method shall remove the duplicates values in the specified slice of string.
 */
@@ -26,16 +34,30 @@ This method shall transform the specified data structure into a JSON content typ
 If the transformation is failed, code 400 shall be caught and displayed, else code 200 shall be caught and displayed.
 */
 func WriteJson(w http.ResponseWriter, data interface{}) { 
-   bytes, err := json.Marshal(data)
+   EncodeJson(data).Write(w)
+} 
+
+/* Encoding a JSON HTTP response.
+This method shall transform the specified data structure into a JSON content, sent later by Write: the data can be encoded while holding the locks
+protecting it, and sent once they are released, so that a slow client does not hold them.
+*/
+func EncodeJson(data interface{}) *JsonResponse {
+   content, err := json.Marshal(data)
+   return &JsonResponse{content:content, err:err}
+}
+
+/* Responsing an encoded JSON HTTP.
+This method shall send an HTTP response of the specified receiver encoded JSON content, as WriteJson does.
+*/
+func (response *JsonResponse) Write(w http.ResponseWriter) {
    // JSON HTTP incorrect: code 400
-   if err != nil {
+   if response.err != nil {
       w.WriteHeader(http.StatusBadRequest)
-      w.Write([]byte(err.Error()))
+      w.Write([]byte(response.err.Error()))
       return
    }
    // JSON HTTP correct: code 200
    w.Header().Add("content-type", "application/json")
    w.WriteHeader(http.StatusOK)
-   w.Write(bytes)
-} 
-
+   w.Write(response.content)
+}
@@ -1,81 +1,400 @@
 package main
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"flag"
+	. "JobStorage"
+	. "Scheduling"
+	. "Streaming"
 	. "UrlCrawling"
 	. "Utilities"
+	. "Webhooks"
+	. "WorkerPool"
 )
 
 const PORT = ":8080"
+const STORE_DIR = "jobs_store"
+const HISTORY_DIR = "history" // Directory of the crawl histories, in the job store directory
+const SCHEDULES_DIR = "schedules" // Directory of the schedules, in the job store directory
+const CHECKPOINT_INTERVAL = 30 * time.Second
+const DEFAULT_HOST_DELAY_MS = 500
+const DEFAULT_HOST_MAX_CONCURRENT = 2
+const DEFAULT_POOL_SIZE = 64
+const DEFAULT_FETCH_CACHE_TTL_S = 0
+// HTTP client settings used by default, for the jobs not specifying theirs
+const DEFAULT_CONNECT_TIMEOUT_MS = 10000
+const DEFAULT_READ_TIMEOUT_MS = 30000
+const DEFAULT_TOTAL_TIMEOUT_MS = 120000
+const DEFAULT_MAX_BODY_BYTES = 10 << 20
+const DEFAULT_MAX_REDIRECTS = 10
+const DEFAULT_CROSS_HOST_REDIRECTS = true
+const DEFAULT_PUBLIC_URL = "http://localhost" + PORT
 const STATUS = "status"
 const RESULT = "result"
+const EVENTS = "events"
+const ERRORS = "errors"
+const URLS = "urls"
+const REDACTED_VALUE = "redacted" // Value of the headers and cookies of a job as displayed by the end points
+const KEEPALIVE_INTERVAL = 15 * time.Second // Delay between two keepalive comments on an idle event stream
+const PAUSE = "pause"
+const RESUME = "resume"
+
+// Job states as reported in the JobStatus
+const STATE_RUNNING = "running"
+const STATE_PAUSED = "paused"
+const STATE_COMPLETED = "completed"
+const STATE_CANCELLED = "cancelled"
+const STATE_FAILED = "failed"
+const STATE_INTERRUPTED = "interrupted"
+
+// Server-wide defaults of the per-host politeness, used by the jobs not specifying theirs
+var defaultHostDelayMs = flag.Int("host-delay-ms", DEFAULT_HOST_DELAY_MS, "default minimum delay in milliseconds between two requests on a same host")
+var defaultHostMaxConcurrent = flag.Int("host-max-concurrent", DEFAULT_HOST_MAX_CONCURRENT, "default maximum number of concurrent requests on a same host")
+// Server-wide default of the fetch cache duration, used by the jobs not specifying theirs
+var defaultFetchCacheTtlS = flag.Int("fetch-cache-ttl-s", DEFAULT_FETCH_CACHE_TTL_S, "default caching duration in seconds of the crawled pages shared by the jobs, 0 to disable the fetch cache")
+// Server-wide settings of the webhook notifications: secret used by the jobs not specifying theirs, and base URL of the links to the job results
+var defaultWebhookSecret = flag.String("webhook-secret", "", "default secret key of the HMAC signature of the webhook deliveries, unsigned if empty")
+var publicUrl = flag.String("public-url", DEFAULT_PUBLIC_URL, "base URL of the API as reached by the webhook receivers")
+
+// Job lifecycle events POSTed to the callback URL of the jobs
+const EVENT_STARTED = "job.started"
+const EVENT_COMPLETED = "job.completed"
+const EVENT_FAILED = "job.failed"
+const EVENT_CANCELLED = "job.cancelled"
+var JOB_EVENTS = map[string]bool{EVENT_STARTED:true, EVENT_COMPLETED:true, EVENT_FAILED:true, EVENT_CANCELLED:true}
+
+// Job progress events streamed on /jobs/{job_id}/events
+const STREAM_URL_DEQUEUED = "url_dequeued"
+const STREAM_PAGE_CRAWLED = "page_crawled"
+const STREAM_IMAGES_FOUND = "images_found"
+const STREAM_ERROR = "error"
+const STREAM_RETRY = "retry"
+const STREAM_PROGRESS = "progress"
+const STREAM_STATUS = "status"
 
 
 
 /* Job definition as per added in the entry point:
 - job_id: unique id of the job,
 - urls: Job URLs,
-- workers: specified number of workers*/
+- workers: specified number of workers, indeed maximum share of the shared worker pool,
+- robots_user_agent: user-agent token which robots.txt rules are honoured,
+- host_delay_ms: minimum delay in milliseconds between two requests on a same host, the server-wide one if none, 0 for no delay,
+- host_max_concurrent: maximum number of concurrent requests on a same host,
+- max_depth: maximum number of hops (followed links) from a Job URL to the crawled URLs,
+- image_types: types of the images to collect (png, jpeg, webp...),
+- verify_image_types: whether the type of the images with missing or ambiguous extension is detected from their Content-Type or magic bytes,
+- extractors: names of the extractors of the data collected from the crawled pages (images, scripts, stylesheets, media, documents),
+- sitemaps: whether the Job URLs crawling is seeded from the sitemaps of their host,
+- frontier: strategy deciding the crawling order of the waiting URLs (bfs, dfs, best_first, round_robin),
+- fetch_cache_ttl_s: caching duration in seconds of the crawled pages, shared with the other jobs reaching them, 0 to always fetch the pages,
+- connect_timeout_ms, read_timeout_ms, total_timeout_ms: maximum durations in milliseconds of the connection to a host, of the wait for the response
+  headers and between two reads of the response body, and of a whole request,
+- max_body_bytes: maximum size in bytes of a crawled page, larger pages failing,
+- max_redirects: maximum number of redirects followed by a request, 0 not to follow any,
+- cross_host_redirects: whether the redirects to another host than the requested one are followed,
+- user_agent: User-Agent header of the requests, the Go default one if empty, robots.txt rules being still matched against robots_user_agent,
+- headers: headers (values) by name (keys) sent with the requests (Accept-Language...),
+- cookies: cookies sent from the first request (name, value, domain, path), the cookies set by the crawled pages being then kept for the whole job,
+- callback_url: URL the job lifecycle events are POSTed to, none if empty,
+- callback_events: lifecycle events POSTed to the callback URL (job.started, job.completed, job.failed, job.cancelled), all if empty,
+- callback_secret: secret key of the HMAC signature of the events, the server-wide one if empty; the events are POSTed without signature header if
+  neither is set,
+- retry: retry policy of the URLs which could not be crawled because of a transient failure (max_attempts, base_backoff_ms, max_backoff_ms,
+  retryable_statuses), the default one if none*/
 type JobDef struct {
 	Job_id string `json:"job_id"`
 	Urls []string `json:"urls"`
 	NbWorkers int `json:"workers"`
+	RobotsAgent string `json:"robots_user_agent"`
+	HostDelayMs *int `json:"host_delay_ms"`
+	HostMaxConcurrent int `json:"host_max_concurrent"`
+	MaxDepth *int `json:"max_depth"`
+	ImageTypes []string `json:"image_types"`
+	VerifyImageTypes bool `json:"verify_image_types"`
+	Extractors []string `json:"extractors"`
+	UseSitemaps bool `json:"sitemaps"`
+	Frontier string `json:"frontier"`
+	FetchCacheTtlS *int `json:"fetch_cache_ttl_s"`
+	ConnectTimeoutMs int `json:"connect_timeout_ms"`
+	ReadTimeoutMs int `json:"read_timeout_ms"`
+	TotalTimeoutMs int `json:"total_timeout_ms"`
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	MaxRedirects *int `json:"max_redirects"`
+	CrossHostRedirects *bool `json:"cross_host_redirects"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies []*InitialCookie `json:"cookies,omitempty"`
+	CallbackUrl string `json:"callback_url,omitempty"`
+	CallbackEvents []string `json:"callback_events,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+	Retry *RetryPolicy `json:"retry,omitempty"`
 }
 
-/* Job status with number of completed and in_progress Job URLs */
+/* Job status:
+- state: job state (running, paused, completed, failed, cancelled, interrupted),
+- completed, in_progress: number of completed and in_progress Job URLs,
+- robots_skipped: number of URLs skipped by robots.txt,
+- unchanged: number of pages not modified since the last crawl of the Job URLs,
+- failed: number of URLs which could not be crawled,
+- seeds: counts of the URLs of each Job URL (keys),
+- images: number of distinct images found per Job URL, summed over the Job URLs,
+- bytes: number of bytes downloaded, pages and assets,
+- started_at, last_activity_at, finished_at: times when the job started, when a URL was crawled last, and when the job ended, none while not over,
+- elapsed_s: seconds elapsed since the job started, until its end once over.*/
 type JobStatus struct {
 	sync.Mutex
+	State string `json:"state"`
 	Completed int `json:"completed"`
 	InProgress int `json:"in_progress"`
+	RobotsSkipped int `json:"robots_skipped"`
+	Unchanged int `json:"unchanged"`
+	Failed int `json:"failed"`
+	Seeds map[string]*SeedStatus `json:"seeds,omitempty"`
+	Images int `json:"images"`
+	Bytes int64 `json:"bytes"`
+	StartedAt time.Time `json:"started_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ElapsedS float64 `json:"elapsed_s"`
 }
 
-/* Result images per Job URL */
-type JobResult map[string][]string 
+/* Counts of the URLs of a Job URL: waiting to be crawled, being crawled, crawled, and which could not be crawled */
+type SeedStatus struct {
+	Waiting int `json:"waiting"`
+	Processing int `json:"processing"`
+	Crawled int `json:"crawled"`
+	Failed int `json:"failed"`
+}
+
+/* Result data per Job URL, grouped by extractor name: extractor name -> Job URL -> data */
+type JobResult map[string]map[string]ResultData
+
+/* Data of a Job URL for an extractor: records values (keys) with their details (values), srcset descriptors of an image for instance, empty if none */
+type ResultData map[string][]string
+
+/* Failures of the URLs which could not be crawled, per Job URL: Job URL -> URL -> failure */
+type JobErrors map[string]map[string]*UrlFailure
+
+/* HTTP status code of the last response of the fetched URLs, whether crawled successfully or not, per Job URL: Job URL -> URL -> HTTP status code */
+type JobUrls map[string]map[string]int
 
 /* Information during Job processing:
-- urlsProcesses: information related (keys) to each Job URLs (keys) crawling process.*/
+- urlsProcesses: information related (keys) to each Job URLs (keys) crawling process,
+- ctx: context of the job, done when the job is cancelled,
+- cancel: function cancelling the ctx context,
+- config: crawling settings of the job, shared by all its Job URLs processes,
+- frontier: frontier of the job, ordering the waiting URLs of all its Job URLs,
+- paused: whether the job is paused, protected by the JobProcess lock,
+- tasks: crawling tasks of the job being performed by the pool workers,
+- completed: channel closed when all the Job URLs are completed (completeOnce making sure it is closed once),
+- notifier: notifier of the job lifecycle events to the callback URL of the job, nil if none,
+- events: broker of the job progress events, streamed to the clients of the job events end point,
+- progress: last progress published to the events broker, protected by the JobProcess lock,
+- lastActivity: time when a URL was crawled last, protected by the JobProcess lock.*/
 type JobProcess struct {
+	sync.Mutex
 	urlsProcesses map[string]*UrlProcess
+	ctx context.Context
+	cancel context.CancelFunc
+	config *CrawlConfig
+	frontier Frontier
+	paused bool
+	tasks sync.WaitGroup
+	completed chan struct{}
+	completeOnce sync.Once
+	notifier *Notifier
+	events *Broker
+	progress JobProgress
+	lastActivity time.Time
 }
 
 /* Job definition with all its data:
 - Def: as per the JSON of the adding Job entry point,
 - Process: information during job processing,
 - Stats: number of completed and in-process URLs,
-- Result: images per Job URL.*/
+- Result: data per extractor and Job URL,
+- Errors: failures of the URLs which could not be crawled, per Job URL,
+- Urls: HTTP status codes of the fetched URLs, per Job URL.*/
 type Job struct {
 	Def *JobDef
 	Process *JobProcess
 	Status *JobStatus
 	Result *JobResult
+	Errors *JobErrors
+	Urls *JobUrls
 }
 
-/* Map of all the jobs (values) defined by their unique job_id (keys) */
+/* Map of all the jobs (values) defined by their unique job_id (keys), persisted in the store, and processed by the shared worker pool.
+The crawl histories of the jobs are persisted in the history store. */
 type Jobs struct {
+	sync.Mutex
 	jobs map[string]*Job
+	store Store
+	history Store
+	pool *Pool
+}
+
+/* Job record as persisted in the store: definition, status, result, errors and HTTP status codes of the URLs of the job,
+and checkpoint of the crawling process of each Job URL (keys) while the job is not over.*/
+type JobRecord struct {
+	Def *JobDef `json:"def"`
+	Status *JobStatus `json:"status"`
+	Result *JobResult `json:"result"`
+	Errors *JobErrors `json:"errors,omitempty"`
+	Urls *JobUrls `json:"urls,omitempty"`
+	Frontier map[string]*UrlProcessCheckpoint `json:"frontier,omitempty"`
+}
+
+/* Job lifecycle event as POSTed to the callback URL of the job: event name, job_id, time of the event, job status, and link to the job result */
+type JobEvent struct {
+	Event string `json:"event"`
+	Job_id string `json:"job_id"`
+	Time time.Time `json:"time"`
+	Status *JobStatus `json:"status"`
+	Result_url string `json:"result_url"`
+}
+
+/* Number of completed and in_progress Job URLs, as streamed in the progress events */
+type JobProgress struct {
+	Completed int `json:"completed"`
+	InProgress int `json:"in_progress"`
+}
+
+/* URL progress event as streamed on the job events end point: Job URL, crawled URL and its depth, images found in the page with their details or failure,
+and for a URL to be retried, its number of failed attempts and the time of its retry */
+type UrlEvent struct {
+	Seed string `json:"seed"`
+	Url string `json:"url"`
+	Depth int `json:"depth"`
+	Images ResultData `json:"images,omitempty"`
+	Error *UrlFailure `json:"error,omitempty"`
+	Attempts int `json:"attempts,omitempty"`
+	RetryAt *time.Time `json:"retry_at,omitempty"`
 }
 
+/* Schedule definition as per added in the entry point:
+- schedule_id: unique id of the schedule,
+- schedule: cron expression ("0 2 * * *"), alias ("@daily") or interval expression ("@every 6h") of the schedule ticks,
+- overlap: policy when the job spawned last is still running or paused at a tick (skip, queue, allow),
+- job: definition of the jobs spawned at each tick, a new job_id being given to each of them*/
+type ScheduleDef struct {
+	Schedule_id string `json:"schedule_id"`
+	Expression string `json:"schedule"`
+	Overlap string `json:"overlap"`
+	Job *JobDef `json:"job"`
+}
 
+/* Job spawned by a schedule, with its spawning time */
+type SpawnedJob struct {
+	Job_id string `json:"job_id"`
+	Time time.Time `json:"time"`
+}
+
+/* Schedule status with the time of the next tick, whether a job is queued, the number of skipped ticks, and the history of the spawned jobs */
+type ScheduleStatus struct {
+	sync.Mutex
+	NextRun *time.Time `json:"next_run,omitempty"`
+	Queued bool `json:"queued"`
+	Skipped int `json:"skipped"`
+	Spawned []SpawnedJob `json:"spawned"`
+}
+
+/* Schedule definition with its status, the runner spawning its jobs, and whether it is deleted (protected by the status lock) */
+type Schedule struct {
+	Def *ScheduleDef
+	Status *ScheduleStatus
+	runner *Runner
+	deleted bool
+}
+
+/* Schedule record as persisted in the schedule store, and displayed by the schedule end points: definition (redacted once displayed) and status of the
+schedule */
+type ScheduleRecord struct {
+	Def *ScheduleDef `json:"def"`
+	Status *ScheduleStatus `json:"status"`
+}
+
+/* Map of all the schedules (values) defined by their unique schedule_id (keys), persisted in the store, and spawning jobs in allJobs */
+type Schedules struct {
+	sync.Mutex
+	schedules map[string]*Schedule
+	store Store
+	allJobs *Jobs
+}
+
+
+
+/* Decoding result data.
+This method shall decode the specified JSON result data into the specified receiver data, the records listed without details by the former job
+records being accepted with no details.
+*/
+func (data *ResultData) UnmarshalJSON(bytes []byte) error {
+	var values []string
+	if(json.Unmarshal(bytes, &values) == nil){
+		*data = ResultData{}
+		for _, value := range values {
+			(*data)[value] = []string{}
+		}
+		return nil
+	}
+	var details map[string][]string
+	if err := json.Unmarshal(bytes, &details); err != nil {
+		return err
+	}
+	*data = ResultData(details)
+	return nil
+}
+
+// Helper function to add the specified details to the specified details list, each detail being listed once, sorted
+func mergeDetails(details []string, moreDetails []string) []string {
+	merged := RemoveSliceDuplicates(append(append([]string{}, details...), moreDetails...))
+	sort.Strings(merged)
+	return merged
+}
 
 /* Updating job summary.
 This method shall update the status and result parameters of the receiver specified job:
-- the status shall consist in the number of in_progress Job URLs and completed Job URLs.
+- the status shall consist in the number of in_progress Job URLs and completed Job URLs, the number of URLs skipped by robots.txt or failed, the counts of
+waiting, processing, crawled and failed URLs of each Job URL, the number of images found, the number of bytes downloaded, the time of the last
+activity and the time elapsed.
 A Job URL shall be considered as completed when no more waiting URLs neither processing URLs related to this URL. The Job URL shall be considered as in_progress otherwise.
-- the result shall consist in listing all unique data (images) retrieved from the crawling process for each of the Job URLs.
+- the result shall consist in listing all unique data retrieved from the crawling process for each of the Job URLs, grouped by extractor of the job,
+with their details.
+- the errors shall consist in the failures of the URLs which could not be crawled for each of the Job URLs,
+- the urls shall consist in the HTTP status code of the last response of the fetched URLs for each of the Job URLs.
 */
 func (job *Job) UpdateJobStatus() {
+	// Nothing to update for a job restored from the store, not being processed
+	if(job.Process == nil){
+		return
+	}
+
 	// Retrieving the process information for each Job URL
 	urlsProcesses := job.Process.urlsProcesses
 
 	completed := 0
 	inProgress := 0
+	robotsSkipped := 0
+	unchanged := 0
+	images := 0
+	totalFailed := 0
+	var downloaded int64
+	seeds := map[string]*SeedStatus{}
 	// Looping on each Job URL from the receiver specified job, and accessing to its process information
 	for jobUrl, jobUrlProcess := range urlsProcesses {
 		waitingUrls := jobUrlProcess.WaitingUrls
@@ -92,17 +411,36 @@ func (job *Job) UpdateJobStatus() {
 			// Considering a Job URL as progressing if previous condition not fullfilled 
 			inProgress++
 		}
+		failures := jobUrlProcess.Failures()
+		(*job.Errors)[jobUrl] = failures
+		(*job.Urls)[jobUrl] = jobUrlProcess.HttpStatuses()
+		failed := len(failures)
+		totalFailed += failed
+		seedStatus := &SeedStatus{Waiting:len(waitingUrls.Urls), Processing:len(processingUrls.UrlsData), Crawled:len(crawledUrls.UrlsData) - failed, Failed:failed}
+		if(seedStatus.Crawled < 0){
+			seedStatus.Crawled = 0
+		}
+		seeds[jobUrl] = seedStatus
 
-		completedData := []string{}
-		// Appending data from each crawled URLs for the Job URL
-		for _, urlsData := range crawledUrls.UrlsData {
-			for data, _ := range urlsData {
-	        	completedData = append(completedData, data)
-	    	} 
+		for _, extractorName := range job.Def.Extractors {
+			// Merging data of the extractor from each crawled URLs for the Job URL, with their details listed once each
+			completedData := ResultData{}
+			for _, urlsData := range crawledUrls.UrlsData {
+				for data, details := range urlsData[extractorName] {
+					completedData[data] = mergeDetails(completedData[data], SplitDetails(details))
+		    	} 
+		    }
+	      	if _, existing := (*job.Result)[extractorName]; !existing {
+	      		(*job.Result)[extractorName] = map[string]ResultData{}
+	      	}
+	      	(*job.Result)[extractorName][jobUrl] = completedData
+	      	if(extractorName == IMAGES_EXTRACTOR){
+	      		images += len(completedData)
+	      	}
 	    }
-	     // Removing data duplicates
-      	completedData = RemoveSliceDuplicates(completedData)
-      	(*job.Result)[jobUrl] = completedData
+      	robotsSkipped += jobUrlProcess.CountRobotsSkipped()
+      	unchanged += jobUrlProcess.CountUnchanged()
+      	downloaded += jobUrlProcess.CountDownloaded()
 
       	waitingUrls.Unlock()
 		processingUrls.Unlock()
@@ -112,111 +450,422 @@ func (job *Job) UpdateJobStatus() {
     // Setting Job status
     job.Status.Completed = completed
     job.Status.InProgress = inProgress
+    job.Status.RobotsSkipped = robotsSkipped
+    job.Status.Unchanged = unchanged
+    job.Status.Failed = totalFailed
+    job.Status.Seeds = seeds
+    job.Status.Images = images
+    job.Status.Bytes = downloaded
+    job.Process.Lock()
+    job.Status.LastActivityAt = job.Process.lastActivity
+    job.Process.Unlock()
+    if(job.Status.FinishedAt != nil){
+    	job.Status.ElapsedS = job.Status.FinishedAt.Sub(job.Status.StartedAt).Seconds()
+    } else {
+    	job.Status.ElapsedS = time.Since(job.Status.StartedAt).Seconds()
+    }
  }
 
 
-/* Job worker in action.
-This method shall define the work cycle of the worker specified by its workerId as following:
-1- The worker shall iterate through the Job URLs defined in the specified receiver job (Urls from Def parameter) and select the first URL available in the related waiting URLs set.
-2- The worker shall select the first available URL by:  
-	- removing this URL from the waiting URLs set,
-	- adding this URL to the processing URLs set,
-	- crawling the selected URL,
-	- adding this URL and its data to the crawled URLs set,
-	- removing this URL from the processing URLs set.
-   Else, the worker shall:
-	- update the specified receiver job,
-	- leave the job only if all the Job URLs are completed; looking for a new URL to crawl else.
+/* Saving job.
+This method shall update the specified receiver job, and save its definition, status, result and errors as a JobRecord in the specified store.
+While the job is running or paused, the checkpoint of the crawling process of each Job URL shall be saved too, so that the job can be resumed.
+It shall be called with the job status locked.
+*/
+func (job *Job) SaveJob(store Store) {
+	job.UpdateJobStatus()
+	jobRecord := &JobRecord{Def:job.Def, Status:job.Status, Result:job.Result, Errors:job.Errors, Urls:job.Urls}
+	if((job.Process != nil) && ((job.Status.State == STATE_RUNNING) || (job.Status.State == STATE_PAUSED))){
+		jobRecord.Frontier = map[string]*UrlProcessCheckpoint{}
+		for jobUrl, jobUrlProcess := range job.Process.urlsProcesses {
+			jobRecord.Frontier[jobUrl] = jobUrlProcess.Checkpoint()
+		}
+	}
+	record, err := json.Marshal(jobRecord)
+	if err == nil {
+		err = store.Save(job.Def.Job_id, record)
+	}
+	if err != nil {
+		fmt.Println("ERROR: Failed to save Job_" + job.Def.Job_id + ": " + err.Error())
+	}
+}
+
+/* Getting the history key of a job.
+This method shall return the key of the crawl history of the specified receiver job, shared by the jobs crawling the same seed set: the same
+Job URLs, whatever their order, with the same extractors and image types.
+*/
+func (job *Job) HistoryKey() string {
+	seeds := append([]string{}, job.Def.Urls...)
+	sort.Strings(seeds)
+	imageTypes := append([]string{}, job.Def.ImageTypes...)
+	sort.Strings(imageTypes)
+	seedSet, _ := json.Marshal([][]string{seeds, job.Def.Extractors, imageTypes})
+	hash := sha1.Sum(seedSet)
+	return hex.EncodeToString(hash[:])
+}
+
+/* Loading job history.
+This method shall load from the specified store the pages recorded by the last crawl of the seed set of the specified receiver job, and give them
+to its crawling process, so that the pages not modified since are not downloaded again. The history shall start empty if none could be loaded.
+*/
+func (job *Job) LoadHistory(store Store) {
+	previous := map[string]*PageRecord{}
+	if bytes, err := store.Load(job.HistoryKey()); err == nil {
+		if err := json.Unmarshal(bytes, &previous); err != nil {
+			fmt.Println("ERROR: Failed to load the crawl history of Job_" + job.Def.Job_id + ": " + err.Error())
+		}
+	}
+	job.Process.config.History = NewCrawlHistory(previous)
+}
+
+/* Saving job history.
+This method shall save in the specified store the pages recorded by the crawling process of the specified receiver job, for the later crawls of its seed set.
+*/
+func (job *Job) SaveHistory(store Store) {
+	record, err := json.Marshal(job.Process.config.History.Records())
+	if err == nil {
+		err = store.Save(job.HistoryKey(), record)
+	}
+	if err != nil {
+		fmt.Println("ERROR: Failed to save the crawl history of Job_" + job.Def.Job_id + ": " + err.Error())
+	}
+}
+
+/* Notifying a job event.
+This method shall queue the specified lifecycle event of the specified receiver job, with its current status, for delivery to its callback URL, if any.
+It shall be called with the job status locked.
+*/
+func (job *Job) NotifyEvent(event string) {
+	if((job.Process == nil) || (job.Process.notifier == nil)){
+		return
+	}
+	resultUrl := strings.TrimSuffix(*publicUrl, "/") + "/jobs/" + job.Def.Job_id + "/" + RESULT
+	payload, err := json.Marshal(&JobEvent{Event:event, Job_id:job.Def.Job_id, Time:time.Now(), Status:job.Status, Result_url:resultUrl})
+	if err != nil {
+		fmt.Println("ERROR: Failed to notify " + event + " event of Job_" + job.Def.Job_id + ": " + err.Error())
+		return
+	}
+	job.Process.notifier.Notify(event, payload)
+}
+
+/* Checking job failure.
+This method shall return whether the specified receiver job failed, indeed none of its Job URLs could be crawled.
+*/
+func (job *Job) Failed() bool {
+	for _, jobUrlProcess := range job.Process.urlsProcesses {
+		if(!jobUrlProcess.SeedFailed()){
+			return false
+		}
+	}
+	return true
+}
+
+/* Getting job end.
+This method shall return a channel closed once the specified receiver job is over (completed or cancelled), already closed if not being processed.
+*/
+func (job *Job) Done() <-chan struct{} {
+	if(job.Process == nil){
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return job.Process.ctx.Done()
+}
+
+/* Pausing job process.
+This method shall make the pool workers stop taking URLs to crawl from the specified receiver job process, until resumed.
+The waiting, processing and crawled URLs sets shall be kept intact.
+*/
+func (jobProcess *JobProcess) Pause() {
+	jobProcess.Lock()
+	jobProcess.paused = true
+	jobProcess.Unlock()
+}
+
+/* Resuming job process.
+This method shall let the pool workers take URLs to crawl from the specified receiver job process again.
+The pool shall be notified by the caller, so that its idle workers look for the job tasks.
+*/
+func (jobProcess *JobProcess) Resume() {
+	jobProcess.Lock()
+	jobProcess.paused = false
+	jobProcess.Unlock()
+}
+
+/* Checking job process pause.
+This method shall return whether the specified receiver job process is paused.
+*/
+func (jobProcess *JobProcess) IsPaused() bool {
+	jobProcess.Lock()
+	defer jobProcess.Unlock()
+	return jobProcess.paused
+}
+
+/* Counting job process progress.
+This method shall return the number of completed Job URLs of the specified receiver job process, without any more waiting URLs neither processing URLs,
+and the number of in_progress Job URLs, the other ones. Unlike UpdateJobStatus, the crawled data shall not be gone through.
+*/
+func (jobProcess *JobProcess) CountProgress() JobProgress {
+	progress := JobProgress{}
+	for _, jobUrlProcess := range jobProcess.urlsProcesses {
+		waitingUrls := jobUrlProcess.WaitingUrls
+		processingUrls := jobUrlProcess.ProcessingUrls
+		waitingUrls.Lock()
+		processingUrls.Lock()
+		pending := len(waitingUrls.Urls) + len(processingUrls.UrlsData)
+		processingUrls.Unlock()
+		waitingUrls.Unlock()
+		if(pending > 0){
+			progress.InProgress++
+		} else {
+			progress.Completed++
+		}
+	}
+	return progress
+}
+
+/* Checking job process completion.
+This method shall consider the specified receiver job process as completed, closing its completed channel, when there are no more waiting URLs
+neither processing URLs for any of its Job URLs.
+*/
+func (jobProcess *JobProcess) CheckCompleted() {
+	if(jobProcess.CountProgress().InProgress == 0){
+		jobProcess.completeOnce.Do(func() { close(jobProcess.completed) })
+	}
+}
+
+/* Publishing job progress.
+This method shall publish the number of completed and in_progress Job URLs of the specified receiver job process to its events broker,
+if changed since last published and if the job events are streamed to any client.
+*/
+func (jobProcess *JobProcess) PublishProgress() {
+	if(!jobProcess.events.Active()){
+		return
+	}
+	jobProcess.Lock()
+	defer jobProcess.Unlock()
+	progress := jobProcess.CountProgress()
+	if(progress != jobProcess.progress){
+		jobProcess.progress = progress
+		jobProcess.events.Publish(STREAM_PROGRESS, progress)
+	}
+}
+
+/* Publishing job status.
+This method shall publish the status of the specified receiver job to its events broker, when its state changes.
+It shall be called with the job status locked.
 */
-func (job *Job) WorkOnJob(workerId int, wgJob *sync.WaitGroup) {
-	// Giving a name to the worker for traces
-	workerName := "Job_" + job.Def.Job_id + "_worker" + strconv.Itoa(workerId)
+func (job *Job) PublishStatus() {
+	if(job.Process != nil){
+		job.Process.events.Publish(STREAM_STATUS, job.Status)
+	}
+}
 
+/* Getting the next job task.
+This method shall be called by the pool workers to get the next task of the specified receiver job, as following:
+1- No task shall be returned while the job is paused or once cancelled.
+2- The next URL shall be popped from the job frontier, in the order of the job frontier strategy, the stale frontier entries being skipped, and selected by:
+	- removing this URL from the waiting URLs set of its Job URL,
+	- adding this URL to the processing URLs set of its Job URL,
+	- returning the task crawling the selected URL.
+3- No task shall be returned if no URL is waiting.
+*/
+func (job *Job) NextTask() (func(), bool) {
 	jobProcess := job.Process
+	if((jobProcess.ctx.Err() != nil) || jobProcess.IsPaused()){
+		return nil, false
+	}
 
-	// Working...
-	WorkingLoop: 
-	for{
-
-		// For each Job URL defined in the specified receiver job...
-		for jobUrl, jobUrlProcess := range jobProcess.urlsProcesses {
-			waitingUrls := jobUrlProcess.WaitingUrls
-			// Looking for a URL waiting to be crawled
-			waitingUrls.Lock()
-			fmt.Println(workerName + " looking for a task in URL: " + jobUrl + "\n")
-	        if(len(waitingUrls.Urls) > 0){
-	        	// At least one URL ready to be crawled
-	        	// Selecting the first available URL to crawl 
-	        	for waitingUrl, _ := range waitingUrls.Urls {
-	        		fmt.Println(workerName + " getting ready to crawl URL: " + waitingUrl + "\n")
-	        		// Removing the selected URL from the waiting URLs set since ready to be crawled by the worker
-    				delete(waitingUrls.Urls, waitingUrl)
-			        waitingUrls.Unlock()
-			        // Adding the selected URL to the processing URLs set
-			        processingUrls := jobUrlProcess.ProcessingUrls
-			        processingUrls.Lock()
-			        processingUrls.UrlsData[waitingUrl] = map[string]string{}
-			        processingUrls.Unlock()
-
-			        // Performing URL crawling
-			        fmt.Println(workerName + " crawling URL: " + waitingUrl + " ...\n")
-			        jobUrlProcess.CrawlUrl(&waitingUrl)
-
-			        // Ending URL crawling
-			        fmt.Println(workerName + " completed crawling URL: " + waitingUrl + "\n")
-			        // Adding the crawled URL to the crawled URLs set
-			        crawledUrls := jobUrlProcess.CrawledUrls
-			        crawledUrls.Lock()
-			        crawledUrls.UrlsData[waitingUrl] = processingUrls.UrlsData[waitingUrl]
-			        crawledUrls.Unlock()
-			        // Removing the crawled URL from the processing URLs set
-			        processingUrls.Lock()
-			        delete(processingUrls.UrlsData, waitingUrl)
-			        processingUrls.Unlock()
-
-			        fmt.Println(workerName + " finished task on URL: " + waitingUrl + "\n")
-
-			        // Looking back for a new task, new URL to crawl...
-			        goto WorkingLoop
-		        	
-		        }
-	        }
-	        waitingUrls.Unlock()
-	    }
-	    // updating the status of the specified receiver job
-	    job.Status.Lock()
-	    job.UpdateJobStatus()
-	    if(job.Status.Completed == len(job.Def.Urls)){
-	    	// Leaving the job if all the Job URLs are completed
-	    	job.Status.Unlock()
-	    	break WorkingLoop
-	    }
-	    job.Status.Unlock()
-	    
+	for {
+		// Popping the next URL waiting to be crawled
+		jobUrl, waitingUrl, waitingInfo, available := jobProcess.frontier.Pop()
+		if(!available){
+			return nil, false
+		}
+		jobUrlProcess := jobProcess.urlsProcesses[jobUrl]
+		// Moving the selected URL from the waiting URLs set to the processing URLs set, unless stale
+		if(jobUrlProcess.TakeWaitingUrl(waitingUrl, waitingInfo)){
+			jobProcess.events.Publish(STREAM_URL_DEQUEUED, &UrlEvent{Seed:jobUrl, Url:waitingUrl, Depth:waitingInfo.Depth})
+			jobProcess.tasks.Add(1)
+			return func() { job.CrawlTask(jobUrlProcess, waitingUrl, waitingInfo) }, true
+		}
+	}
+}
+
+/* Job task in action.
+This method shall crawl the specified URL, previously moved to the processing URLs set of the specified Job URL process, by:
+- crawling the URL,
+- putting this URL back in the waiting URLs set if its crawling failed for a transient reason, according to the job retry policy,
+- else adding this URL and its data to the crawled URLs set,
+- removing this URL from the processing URLs set,
+- publishing the crawled page with its images found, or the error raised or retry, and the job progress to the job events broker,
+- checking whether the job is completed.
+A URL which crawling has been aborted by the job cancellation shall be put back in the waiting URLs set.
+*/
+func (job *Job) CrawlTask(jobUrlProcess *UrlProcess, urlToCrawl string, urlInfo *UrlInfo) {
+	jobProcess := job.Process
+	defer jobProcess.tasks.Done()
+	// Giving a name to the task for traces
+	taskName := "Job_" + job.Def.Job_id
+
+	// Performing URL crawling
+	fmt.Println(taskName + " crawling URL: " + urlToCrawl + " at depth " + strconv.Itoa(urlInfo.Depth) + " ...\n")
+	jobUrlProcess.CrawlUrl(jobProcess.ctx, &urlToCrawl, urlInfo.Depth)
+
+	processingUrls := jobUrlProcess.ProcessingUrls
+	if(jobProcess.ctx.Err() != nil){
+		// Crawling aborted by the job cancellation: putting back the URL in the waiting URLs set
+		fmt.Println(taskName + " aborted crawling URL: " + urlToCrawl + "\n")
+		waitingUrls := jobUrlProcess.WaitingUrls
+		waitingUrls.Lock()
+		processingUrls.Lock()
+		delete(processingUrls.UrlsData, urlToCrawl)
+		delete(processingUrls.UrlsInfo, urlToCrawl)
+		jobUrlProcess.PushWaitingUrl(urlToCrawl, urlInfo)
+		processingUrls.Unlock()
+		waitingUrls.Unlock()
+		return
 	}
 
-	fmt.Println(workerName + " leaving Job_" + job.Def.Job_id + "\n")
-    wgJob.Done()
+	jobProcess.Lock()
+	jobProcess.lastActivity = time.Now()
+	jobProcess.Unlock()
+
+	// Putting back the URL which crawling failed for a transient reason in the waiting URLs set, to be retried after a backoff
+	if failure, failed := jobUrlProcess.Failure(urlToCrawl); failed {
+		if retryInfo, retried := jobUrlProcess.RetryUrl(urlToCrawl, urlInfo); retried {
+			fmt.Println(taskName + " will retry URL: " + urlToCrawl + " after " + strconv.Itoa(retryInfo.Attempts) + " failed attempts\n")
+			jobProcess.events.Publish(STREAM_RETRY, &UrlEvent{Seed:jobUrlProcess.Seed, Url:urlToCrawl, Depth:urlInfo.Depth, Error:failure, Attempts:retryInfo.Attempts, RetryAt:retryInfo.NotBefore})
+			return
+		}
+	}
+
+	// Ending URL crawling
+	fmt.Println(taskName + " completed crawling URL: " + urlToCrawl + "\n")
+	// Adding the crawled URL to the crawled URLs set, and removing it from the processing URLs set
+	crawledUrls := jobUrlProcess.CrawledUrls
+	processingUrls.Lock()
+	crawledUrls.Lock()
+	crawledUrls.UrlsData[urlToCrawl] = processingUrls.UrlsData[urlToCrawl]
+	crawledUrls.UrlsInfo[urlToCrawl] = urlInfo
+	crawledUrls.Unlock()
+	images := ResultData{}
+	for image, details := range processingUrls.UrlsData[urlToCrawl][IMAGES_EXTRACTOR] {
+		images[image] = SplitDetails(details)
+	}
+	delete(processingUrls.UrlsData, urlToCrawl)
+	delete(processingUrls.UrlsInfo, urlToCrawl)
+	processingUrls.Unlock()
+
+	// Publishing the crawled page and the job progress
+	urlEvent := &UrlEvent{Seed:jobUrlProcess.Seed, Url:urlToCrawl, Depth:urlInfo.Depth}
+	if failure, failed := jobUrlProcess.Failure(urlToCrawl); failed {
+		urlEvent.Error = failure
+		jobProcess.events.Publish(STREAM_ERROR, urlEvent)
+	} else {
+		jobProcess.events.Publish(STREAM_PAGE_CRAWLED, urlEvent)
+		if(len(images) > 0){
+			jobProcess.events.Publish(STREAM_IMAGES_FOUND, &UrlEvent{Seed:jobUrlProcess.Seed, Url:urlToCrawl, Depth:urlInfo.Depth, Images:images})
+		}
+	}
+
+	jobProcess.CheckCompleted()
+	jobProcess.PublishProgress()
 }
 
-/* Job processing.
-This method shall process the specified receiver job by launching as many goroutines as the number of workers defined in the
-specified receiver job.
-The job shall be processing until all the workers have ended their work on this job.
+/* Job seeding.
+This method shall seed the crawling of each Job URL of the specified receiver job from the sitemaps of its host, if enabled for the job.
+The Job URLs shall be seeded concurrently, the method returning once all are seeded, or the job cancelled.
 */
-func (job *Job) ProcessJob() {
-	var wgJob sync.WaitGroup
+func (job *Job) SeedJob() {
+	if(!job.Def.UseSitemaps){
+		return
+	}
 
-	// Launching the goroutines workers to work on the job
-	nbWorkers := job.Def.NbWorkers
-	wgJob.Add(nbWorkers)
-	for i := 0; i < nbWorkers; i++ {
-		go job.WorkOnJob(i, &wgJob)
+	var wgSeed sync.WaitGroup
+	wgSeed.Add(len(job.Process.urlsProcesses))
+	for jobUrl, jobUrlProcess := range job.Process.urlsProcesses {
+		go func(jobUrl string, jobUrlProcess *UrlProcess) {
+			seeded := jobUrlProcess.SeedFromSitemaps(job.Process.ctx)
+			fmt.Println("Job_" + job.Def.Job_id + " seeded " + strconv.Itoa(seeded) + " URLs from the sitemaps of URL: " + jobUrl + "\n")
+			wgSeed.Done()
+		}(jobUrl, jobUrlProcess)
 	}
+	wgSeed.Wait()
+}
+
+/* Job processing.
+This method shall process the specified receiver job by registering it in the specified shared worker pool, with the number of workers defined
+in the specified receiver job as maximum share of the pool workers.
+The job shall be processing until all its Job URLs are completed or the job cancelled, and shall be saved in the specified store periodically.
+The job shall then be unregistered from the pool, and once its last tasks ended, its state shall be set to cancelled if the job has been cancelled,
+to failed if none of its Job URLs could be crawled, to completed else, and the job saved in the specified store. The matching lifecycle event shall be
+notified to the callback URL of the job, if any, and published to the job events broker, closed then.
+The pages crawled by the last crawl of the seed set of the job shall be loaded from the specified history store beforehand, and the pages crawled
+by the job saved in it at the end.
+*/
+func (job *Job) ProcessJob(store Store, history Store, pool *Pool) {
+	jobProcess := job.Process
+	job.LoadHistory(history)
+
+	// Checkpointing the job periodically until the work on job is completed
+	processed := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(CHECKPOINT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+				case <-ticker.C:
+					job.Status.Lock()
+					job.SaveJob(store)
+					job.Status.Unlock()
+				case <-processed:
+					return
+			}
+		}
+	}()
 	
-	// Waiting until the work on job is completed.
-	wgJob.Wait()
-	fmt.Println("Job_ " + job.Def.Job_id + " completed !")
+	// Working on the job with the pool workers until the work on job is completed, or the job cancelled: the workers sleeping for lack of waiting URLs
+	// are woken up as soon as new URLs are pushed
+	jobProcess.config.Notify = pool.Notify
+	pool.Register(job, job.Def.NbWorkers)
+	jobProcess.CheckCompleted()
+	select {
+		case <-jobProcess.completed:
+		case <-jobProcess.ctx.Done():
+	}
+	pool.Unregister(job)
+	jobProcess.tasks.Wait()
+	close(processed)
+
+	// Setting the final state and end time of the job
+	job.Status.Lock()
+	finishedAt := time.Now()
+	job.Status.FinishedAt = &finishedAt
+	job.UpdateJobStatus()
+	event := EVENT_COMPLETED
+	if(job.Process.ctx.Err() != nil){
+		job.Status.State = STATE_CANCELLED
+		event = EVENT_CANCELLED
+	} else if(job.Failed()){
+		job.Status.State = STATE_FAILED
+		event = EVENT_FAILED
+	} else {
+		job.Status.State = STATE_COMPLETED
+	}
+	state := job.Status.State
+	job.SaveJob(store)
+	job.NotifyEvent(event)
+	job.PublishStatus()
+	job.Status.Unlock()
+	job.Process.events.Close()
+	if(job.Process.notifier != nil){
+		job.Process.notifier.Close()
+	}
+	job.SaveHistory(history)
+	job.Process.config.Client.CloseIdleConnections()
+	job.Process.cancel()
+	fmt.Println("Job_" + job.Def.Job_id + " " + state + " !")
 }
 
 
@@ -225,10 +874,14 @@ This method shall initialize a job of Job type by:
 - assigning the specified receiver JobDef to the Def parameter,
 - initializing the urlProcess parameter by creating the UrlProcess for each Job URLs provided by the specified JobDef; indeed for each Job URL:
   the parsed URL of the Job URL, the related waiting URLs, processing URLs and crawled URLs sets.
-- creating the Job Status and Result parameters.
+- creating the crawling settings, the HTTP client with its cookie jar and the frontier of the job from the specified JobDef, shared by all its Job URLs,
+- creating the context of the job, allowing its cancellation,
+- creating the notifier of the job lifecycle events if a callback URL is specified, and the broker of the job progress events,
+- creating the Job Status, Result, Errors and Urls parameters.
 Note 1: at this init step, for each Job URL, the waiting URLs set of urlProcess shall contain only the Job URL, with empty associated data.
 The processing URLs and crawled URLs shall be empty.
-Note 2: at this init step, the InProgress value of the Status parameter shall be initialized to the number of Job URLs.
+Note 2: at this init step, the InProgress value of the Status parameter shall be initialized to the number of Job URLs, and the start time and last
+activity time to the current time.
 */
 func (job *Job) InitJob(jobDef *JobDef) {
 	// Assigning Def parameter
@@ -238,27 +891,97 @@ func (job *Job) InitJob(jobDef *JobDef) {
 	jobProcess := &JobProcess{}
 	job.Process = jobProcess
 
+	jobProcess.ctx, jobProcess.cancel = context.WithCancel(context.Background())
+	jobProcess.completed = make(chan struct{})
+	jobProcess.events = NewBroker()
+
+	if(job.Def.CallbackUrl != ""){
+		secret := job.Def.CallbackSecret
+		if(secret == ""){
+			secret = *defaultWebhookSecret
+		}
+		jobProcess.notifier = NewNotifier(job.Def.CallbackUrl, secret, job.Def.CallbackEvents)
+	}
+
+	crawlConfig := &CrawlConfig{
+		RobotsAgent:job.Def.RobotsAgent,
+		HostMaxConcurrent:job.Def.HostMaxConcurrent,
+		MaxDepth:DEFAULT_MAX_DEPTH,
+		ImageTypes:NewImageTypes(job.Def.ImageTypes),
+		VerifyImageTypes:job.Def.VerifyImageTypes,
+		UseSitemaps:job.Def.UseSitemaps,
+		Stylesheets:NewStylesheetCache(),
+	}
+	if(len(job.Def.Extractors) == 0){
+		job.Def.Extractors = DEFAULT_EXTRACTORS
+	}
+	crawlConfig.Extractors, _ = NewExtractors(job.Def.Extractors)
+	if(job.Def.MaxDepth != nil){
+		crawlConfig.MaxDepth = *job.Def.MaxDepth
+	}
+	crawlConfig.HostDelay = time.Duration(*defaultHostDelayMs) * time.Millisecond
+	if(job.Def.HostDelayMs != nil){
+		crawlConfig.HostDelay = time.Duration(*job.Def.HostDelayMs) * time.Millisecond
+	}
+	if(job.Def.FetchCacheTtlS != nil){
+		crawlConfig.FetchCacheTTL = time.Duration(*job.Def.FetchCacheTtlS) * time.Second
+	}
+	crawlConfig.Retry = job.Def.Retry
+	clientSettings := &ClientSettings{
+		ConnectTimeout:time.Duration(job.Def.ConnectTimeoutMs) * time.Millisecond,
+		ReadTimeout:time.Duration(job.Def.ReadTimeoutMs) * time.Millisecond,
+		TotalTimeout:time.Duration(job.Def.TotalTimeoutMs) * time.Millisecond,
+		MaxRedirects:DEFAULT_MAX_REDIRECTS,
+		CrossHostRedirects:DEFAULT_CROSS_HOST_REDIRECTS,
+	}
+	if(job.Def.MaxRedirects != nil){
+		clientSettings.MaxRedirects = *job.Def.MaxRedirects
+	}
+	if(job.Def.CrossHostRedirects != nil){
+		clientSettings.CrossHostRedirects = *job.Def.CrossHostRedirects
+	}
+	clientSettings.UserAgent = job.Def.UserAgent
+	clientSettings.Headers = job.Def.Headers
+	clientSettings.Cookies = job.Def.Cookies
+	clientSettings.Seeds = job.Def.Urls
+	crawlConfig.Client = NewHttpClient(clientSettings)
+	crawlConfig.Identity = clientSettings.Identity()
+	crawlConfig.MaxBodySize = job.Def.MaxBodyBytes
+	jobProcess.config = crawlConfig
+
+	if(job.Def.Frontier == ""){
+		job.Def.Frontier = DEFAULT_FRONTIER
+	}
+	jobProcess.frontier, _ = NewFrontier(job.Def.Frontier)
+
 	jobProcess.urlsProcesses = make(map[string]*UrlProcess)
-	// For each job URL, initializing the urlProcess for the waitingUrls, processingUrls and crawledUrls sets
+	// For each job URL, initializing the urlProcess for the waitingUrls, processingUrls and crawledUrls sets, sharing the frontier of the job
 	urlsDef := job.Def.Urls
 	for _, url := range urlsDef {
  		urlProcess := &UrlProcess{}
- 		urlProcess.InitUrlProcess(&url)
+ 		urlProcess.InitUrlProcess(&url, crawlConfig, jobProcess.frontier)
     	jobProcess.urlsProcesses[url] = urlProcess
     }
 
     // Initializing JobStatus and Result parameters of Job
-    job.Status = &JobStatus{Completed:0 , InProgress:job.Def.NbWorkers} 
+    now := time.Now()
+    jobProcess.lastActivity = now
+    job.Status = &JobStatus{State:STATE_RUNNING, Completed:0 , InProgress:len(jobProcess.urlsProcesses), StartedAt:now, LastActivityAt:now} 
     job.Result = &JobResult{} 
+    job.Errors = &JobErrors{}
+    job.Urls = &JobUrls{}
 }
 
 /* Getting job status and result end point implementation.
 This method shall read the content of an HTTP request, and make sure that the HTTP request is composed by one of the following synthaxis:
 1- /jobs/{job_id}/status,
-2- /jobs/{job_id}/result.
-If the request is one of the cases 1- and 2- above, and if job_id is existing among the allJobs specified receiver parameter, code 200 shall be displayed, and following shall be performed:
+2- /jobs/{job_id}/result,
+3- /jobs/{job_id}/errors,
+4- /jobs/{job_id}/urls.
+If the request is one of the cases 1- to 4- above, and if job_id is existing among the allJobs specified receiver parameter, code 200 shall be displayed, and following shall be performed:
 - updating the specified job status
-- display the response as a new JSON of JobStatus or JobResult type, respectively if request content is "status" or "result".
+- display the response as a new JSON of JobStatus, JobResult, JobErrors or JobUrls type, respectively if request content is "status", "result",
+  "errors" or "urls".
 Else, code 404 shall be caught and displayed. 
 */
 func (allJobs *Jobs) GetJobData(w http.ResponseWriter, r *http.Request) {
@@ -269,22 +992,30 @@ func (allJobs *Jobs) GetJobData(w http.ResponseWriter, r *http.Request) {
 	urlParts := strings.Split(r.URL.String(), "/")
 
 	// Checking if HTTP request is success: code 200 
-	if  ( (len(urlParts) == 4) && ((urlParts[urlServiceNamePos] == STATUS)||(urlParts[urlServiceNamePos] == RESULT)) ) {
+	if  ( (len(urlParts) == 4) && ((urlParts[urlServiceNamePos] == STATUS)||(urlParts[urlServiceNamePos] == RESULT)||(urlParts[urlServiceNamePos] == ERRORS)||(urlParts[urlServiceNamePos] == URLS)) ) {
 		// Trying to retrieve the requested job among the jobs datastore
 		jobId := urlParts[urlJobIdPos]
+		allJobs.Lock()
 		job, existing := allJobs.jobs[jobId]
+		allJobs.Unlock()
 		if(existing) {
 			// Job existing: updating the status of the requested job, necessary to display either status or result data response
 			job.Status.Lock()
 			job.UpdateJobStatus()
-			job.Status.Unlock()
 
-			// Displaying the JSON response according to the request content 
+			// Encoding the JSON response according to the request content while the job status is locked, and displaying it once unlocked
+			var response *JsonResponse
 			if  (urlParts[urlServiceNamePos] == STATUS) {
-				WriteJson(w, job.Status)
+				response = EncodeJson(job.Status)
 			} else if (urlParts[urlServiceNamePos] == RESULT) {
-		   		WriteJson(w, job.Result)
+		   		response = EncodeJson(job.Result)
+			} else if (urlParts[urlServiceNamePos] == ERRORS) {
+		   		response = EncodeJson(job.Errors)
+			} else if (urlParts[urlServiceNamePos] == URLS) {
+		   		response = EncodeJson(job.Urls)
 			}
+			job.Status.Unlock()
+			response.Write(w)
 		} else {
 			// Job request not exiting: request incorrect: code 404
 			w.WriteHeader(http.StatusNotFound)
@@ -297,17 +1028,272 @@ func (allJobs *Jobs) GetJobData(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+/* Streaming job events end point implementation.
+This method shall read the content of an HTTP request, and make sure that the HTTP request is composed by the synthaxis /jobs/{job_id}/events.
+If job_id is not existing among the allJobs specified receiver parameter, code 404 shall be caught and displayed.
+Else, code 200 shall be caught and displayed, and the job events shall be streamed as Server-Sent Events (text/event-stream) as following:
+- a status event first, with the current job status,
+- then each event published to the job events broker, as they happen: url_dequeued, page_crawled, images_found, error, progress (Completed and
+  InProgress counts, when changed) and status (job state changes),
+- a keepalive comment when no event happened for a while.
+The stream shall end once the job is over, after its final status event, when the client disconnects, or when the client is too slow to keep up
+with the job events (the client reconnecting then gets a new status event). A job not being processed anymore shall
+only stream its status.
+*/
+func (allJobs *Jobs) StreamJobEvents(w http.ResponseWriter, r *http.Request) {
+	urlJobIdPos := 2
+	urlServiceNamePos := 3
+
+	// Reading and splitting the URL given in the request
+	urlParts := strings.Split(r.URL.Path, "/")
+	if( (len(urlParts) != 4) || (urlParts[urlServiceNamePos] != EVENTS) ) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Trying to retrieve the requested job among the jobs datastore
+	allJobs.Lock()
+	job, existing := allJobs.jobs[urlParts[urlJobIdPos]]
+	allJobs.Unlock()
+	if(!existing) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	flusher, streaming := w.(http.Flusher)
+	if(!streaming) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribing before taking the status snapshot, so that no event is missed in between
+	var events <-chan Event
+	if(job.Process != nil){
+		var unsubscribe func()
+		events, unsubscribe = job.Process.events.Subscribe()
+		defer unsubscribe()
+	}
+	job.Status.Lock()
+	job.UpdateJobStatus()
+	data, _ := json.Marshal(job.Status)
+	job.Status.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	WriteEvent(w, Event{Name:STREAM_STATUS, Data:data})
+	flusher.Flush()
+	if(events == nil){
+		return
+	}
+
+	// Streaming the job events until the job is over or the client disconnected
+	keepalive := time.NewTicker(KEEPALIVE_INTERVAL)
+	defer keepalive.Stop()
+	for {
+		select {
+			case event, open := <-events:
+				if(!open){
+					return
+				}
+				if err := WriteEvent(w, event); err != nil {
+					return
+				}
+			case <-keepalive.C:
+				if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+		}
+		flusher.Flush()
+	}
+}
+
+/* Checking a job definition.
+This method shall check the specified receiver job definition, and complete it with the default values of its missing parameters:
+- return an error if one of the extractors or the frontier strategy is unknown, if the callback URL is not an absolute HTTP(S) URL or one of the
+  callback events unknown, if the user agent, headers or cookies cannot be sent, or if the retry policy is incorrect,
+- make sure that there is at least one worker,
+- use the default robots.txt user-agent token if none specified,
+- use the server-wide host delay if none specified, 0 (no delay) if negative, and the server-wide maximum concurrency per host if none specified,
+- use the default maximum depth if none specified, 0 if negative,
+- use the server-wide fetch cache duration if none specified, 0 (no fetch cache) if negative,
+- use the default timeouts, maximum body size, maximum redirects (0 if negative) and cross-host redirects policy if none specified,
+- use the default image types, extractors, frontier and retry policy values if none specified.
+*/
+func (jobDef *JobDef) ApplyDefaults() error {
+	// Checking the extractors: error if unknown
+	if(len(jobDef.Extractors) == 0){
+		jobDef.Extractors = DEFAULT_EXTRACTORS
+	}
+	if _, err := NewExtractors(jobDef.Extractors); err != nil {
+		return err
+	}
+
+	// Checking the frontier strategy: error if unknown
+	if(jobDef.Frontier == ""){
+		jobDef.Frontier = DEFAULT_FRONTIER
+	}
+	if _, err := NewFrontier(jobDef.Frontier); err != nil {
+		return err
+	}
+
+	// Checking the callback URL: error if not an absolute HTTP(S) URL
+	if(jobDef.CallbackUrl != ""){
+		callbackUrl, err := url.Parse(jobDef.CallbackUrl)
+		if (err != nil) || ((callbackUrl.Scheme != "http") && (callbackUrl.Scheme != "https")) || (callbackUrl.Host == "") {
+			return errors.New("callback_url shall be an absolute HTTP(S) URL: " + jobDef.CallbackUrl)
+		}
+	}
+	for _, event := range jobDef.CallbackEvents {
+		if(!JOB_EVENTS[event]){
+			return errors.New("unknown callback event: " + event)
+		}
+	}
+
+	// Checking the user agent, headers and cookies: error if they cannot be sent
+	if err := CheckIdentity(jobDef.UserAgent, jobDef.Headers, jobDef.Cookies); err != nil {
+		return err
+	}
+
+	// Checking the retry policy, and completing it with the default values: error if incorrect
+	if(jobDef.Retry == nil){
+		jobDef.Retry = &RetryPolicy{}
+	}
+	if err := jobDef.Retry.ApplyDefaults(); err != nil {
+		return err
+	}
+
+	// Setting number or workers at least equal to 1
+	if(jobDef.NbWorkers < 1){
+		jobDef.NbWorkers = 1
+	}
+
+	// Setting the default robots.txt user-agent token if none
+	if(jobDef.RobotsAgent == ""){
+		jobDef.RobotsAgent = DEFAULT_ROBOTS_USER_AGENT
+	}
+
+	// Setting the server-wide per-host politeness if none, an explicit host delay of 0 disabling it
+	if(jobDef.HostDelayMs == nil){
+		hostDelayMs := *defaultHostDelayMs
+		jobDef.HostDelayMs = &hostDelayMs
+	} else if(*jobDef.HostDelayMs < 0){
+		*jobDef.HostDelayMs = 0
+	}
+	if(jobDef.HostMaxConcurrent <= 0){
+		jobDef.HostMaxConcurrent = *defaultHostMaxConcurrent
+	}
+
+	// Setting the default maximum depth if none
+	if(jobDef.MaxDepth == nil){
+		maxDepth := DEFAULT_MAX_DEPTH
+		jobDef.MaxDepth = &maxDepth
+	} else if(*jobDef.MaxDepth < 0){
+		*jobDef.MaxDepth = 0
+	}
+
+	// Setting the server-wide fetch cache duration if none
+	if(jobDef.FetchCacheTtlS == nil){
+		fetchCacheTtlS := *defaultFetchCacheTtlS
+		jobDef.FetchCacheTtlS = &fetchCacheTtlS
+	} else if(*jobDef.FetchCacheTtlS < 0){
+		*jobDef.FetchCacheTtlS = 0
+	}
+
+	// Setting the default HTTP client settings if none
+	if(jobDef.ConnectTimeoutMs <= 0){
+		jobDef.ConnectTimeoutMs = DEFAULT_CONNECT_TIMEOUT_MS
+	}
+	if(jobDef.ReadTimeoutMs <= 0){
+		jobDef.ReadTimeoutMs = DEFAULT_READ_TIMEOUT_MS
+	}
+	if(jobDef.TotalTimeoutMs <= 0){
+		jobDef.TotalTimeoutMs = DEFAULT_TOTAL_TIMEOUT_MS
+	}
+	if(jobDef.MaxBodyBytes <= 0){
+		jobDef.MaxBodyBytes = DEFAULT_MAX_BODY_BYTES
+	}
+	if(jobDef.MaxRedirects == nil){
+		maxRedirects := DEFAULT_MAX_REDIRECTS
+		jobDef.MaxRedirects = &maxRedirects
+	} else if(*jobDef.MaxRedirects < 0){
+		*jobDef.MaxRedirects = 0
+	}
+	if(jobDef.CrossHostRedirects == nil){
+		crossHostRedirects := DEFAULT_CROSS_HOST_REDIRECTS
+		jobDef.CrossHostRedirects = &crossHostRedirects
+	}
+
+	// Setting the default image types if none
+	if(len(jobDef.ImageTypes) == 0){
+		jobDef.ImageTypes = DEFAULT_IMAGE_TYPES
+	}
+
+	return nil
+}
+
+/* Redacting a job definition.
+This method shall return a copy of the specified receiver jobDef to be displayed by the end points, without its callback secret, and with the values of
+its headers and cookies replaced by REDACTED_VALUE, since they may hold credentials: none of them shall be returned once the job is added.
+*/
+func (jobDef *JobDef) Redacted() *JobDef {
+	redacted := *jobDef
+	redacted.CallbackSecret = ""
+	if(jobDef.Headers != nil){
+		redacted.Headers = map[string]string{}
+		for name := range jobDef.Headers {
+			redacted.Headers[name] = REDACTED_VALUE
+		}
+	}
+	if(jobDef.Cookies != nil){
+		redacted.Cookies = []*InitialCookie{}
+		for _, cookie := range jobDef.Cookies {
+			redactedCookie := *cookie
+			redactedCookie.Value = REDACTED_VALUE
+			redacted.Cookies = append(redacted.Cookies, &redactedCookie)
+		}
+	}
+	return &redacted
+}
+
+/* Starting a job.
+This method shall start a new job from the specified job definition, already checked:
+- create a unique job_id value,
+- initialize the new job as Job type with the specified job definition,
+- add this new job to the allJobs specified receiver, save it in its store, and notify its start to its callback URL if any,
+- once all the above steps completed, start a goroutine to seed the created job from sitemaps if enabled, and then to process it.
+The new job shall be returned.
+*/
+func (allJobs *Jobs) StartJob(jobDef *JobDef) *Job {
+	// Creating a unique job_id
+	jobDef.Job_id = fmt.Sprintf("%d", time.Now().UnixNano())
+
+	// Initializing the new job and adding it to allJobs
+	newJob := &Job{}
+	newJob.InitJob(jobDef)
+	allJobs.Lock()
+	allJobs.jobs[jobDef.Job_id] = newJob
+	allJobs.Unlock()
+	newJob.Status.Lock()
+	newJob.SaveJob(allJobs.store)
+	newJob.NotifyEvent(EVENT_STARTED)
+	newJob.Status.Unlock()
+
+	// Starting the goroutine to process the job
+	go func() {
+		newJob.SeedJob()
+		newJob.ProcessJob(allJobs.store, allJobs.history, allJobs.pool)
+	}()
+	return newJob
+}
+
 /* Adding job end point implementation.
 This method shall read the content of an HTTP request, and make sure that the request is JSON content type.
 The JSON decoded shall be a JobDef structure type. 
-If the request is not a JSON content type or malformed JSON, code 400 shall be caught and displayed.
-Else, code 200 shall be caught and displayed, and following shall be performed:
-- create a unique job_id value,
-- make sure that there is at least one worker,
-- display the response as a new JSON of JobDef type that shall be the same as the request one, with the value to job_id added,
-- initialize the new job as Job type with the parameters specified in the request,
-- add this new job to the allJobs specified receiver,
-- once all the above steps completed, start a goroutine to process the created job.
+If the request is not a JSON content type or malformed JSON, or if the job definition is incorrect (see ApplyDefaults), code 400 shall be caught and displayed.
+Else, the job shall be started (see StartJob), and code 200 shall be caught and displayed with the response as a new JSON of JobDef type that shall be
+the same as the request one, with the value to job_id and the default values added, redacted (see JobDef.Redacted).
 */
 func (allJobs *Jobs) AddJob(w http.ResponseWriter, r *http.Request) {
 	jobDef := &JobDef{}
@@ -318,46 +1304,541 @@ func (allJobs *Jobs) AddJob(w http.ResponseWriter, r *http.Request) {
 	// Decoding the JSON content: code 400 is incorrect 
 	ct := r.Header.Get("content-type")
 	err := json.Unmarshal(bytes, jobDef)
-	if (err != nil) || (ct != "application/json") {
+	if (err == nil) && (ct != "application/json") {
+		err = errors.New("content-type shall be application/json")
+	}
+	if err == nil {
+		err = jobDef.ApplyDefaults()
+	}
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	} 
 
-	// Creating a unique job_id
-	jobDef.Job_id = fmt.Sprintf("%d", time.Now().UnixNano())
+	// Starting the job, and displaying the JSON response with job_id defined, and code 200 if success
+	allJobs.StartJob(jobDef)
+	WriteJson(w, jobDef.Redacted())
+}
 
-	// Setting number or workers at least equal to 1
-	if(jobDef.NbWorkers < 1){
-		jobDef.NbWorkers = 1
+/* Cancelling job end point implementation.
+This method shall read the content of an HTTP request, and make sure that the HTTP request is composed by the synthaxis /jobs/{job_id}.
+If job_id is not existing among the allJobs specified receiver parameter, code 404 shall be caught and displayed.
+If the job is not running nor paused anymore (completed or already cancelled), code 409 shall be caught and displayed.
+Else, code 200 shall be caught and displayed, and following shall be performed:
+- cancel the context of the job, aborting the in-flight crawls and making the workers leave the job,
+- publish its new status to the job events broker,
+- display the response as a new JSON of JobStatus type.
+The job state shall become cancelled once all its workers have left; the result collected so far shall be kept.
+*/
+func (allJobs *Jobs) CancelJob(w http.ResponseWriter, r *http.Request) {
+	urlJobIdPos := 2
+
+	// Reading and splitting the URL given in the request
+	urlParts := strings.Split(r.URL.Path, "/")
+	if(len(urlParts) != 3) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	// Displaying the JSON response with job_id defined, and code 200 if success
-	WriteJson(w, jobDef)
+	// Trying to retrieve the requested job among the jobs datastore
+	allJobs.Lock()
+	job, existing := allJobs.jobs[urlParts[urlJobIdPos]]
+	allJobs.Unlock()
+	if(!existing) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 
-	// Initializing the new job and adding it to allJobs
-	newJob := &Job{}
-	newJob.InitJob(jobDef)
-	allJobs.jobs[jobDef.Job_id] = newJob
+	// Checking that the job is still running or paused: code 409 else
+	job.Status.Lock()
+	if((job.Status.State != STATE_RUNNING) && (job.Status.State != STATE_PAUSED)) {
+		job.Status.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
 
-	// Starting the goroutine to process the job
-	ready := true
-	if(ready){
-		go newJob.ProcessJob()	
+	// Cancelling the job
+	job.Process.cancel()
+	job.Status.State = STATE_CANCELLED
+	job.SaveJob(allJobs.store)
+	job.PublishStatus()
+	response := EncodeJson(job.Status)
+	job.Status.Unlock()
+	response.Write(w)
+}
+
+/* Pausing and resuming job end points implementation.
+This method shall read the content of an HTTP request, and make sure that the HTTP request is composed by one of the following synthaxis:
+1- /jobs/{job_id}/pause,
+2- /jobs/{job_id}/resume.
+If the request is none of the cases 1- and 2- above, or if job_id is not existing among the allJobs specified receiver parameter, code 404 shall be caught and displayed.
+If the job is not running (case 1-) or not paused (case 2-), code 409 shall be caught and displayed.
+Else, code 200 shall be caught and displayed, and following shall be performed:
+- pause or resume the job, respectively for cases 1- and 2-, and publish its new status to the job events broker,
+- display the response as a new JSON of JobStatus type.
+*/
+func (allJobs *Jobs) PauseResumeJob(w http.ResponseWriter, r *http.Request) {
+	urlJobIdPos := 2
+	urlServiceNamePos := 3
+
+	// Reading and splitting the URL given in the request
+	urlParts := strings.Split(r.URL.Path, "/")
+	if( (len(urlParts) != 4) || ((urlParts[urlServiceNamePos] != PAUSE) && (urlParts[urlServiceNamePos] != RESUME)) ) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Trying to retrieve the requested job among the jobs datastore
+	allJobs.Lock()
+	job, existing := allJobs.jobs[urlParts[urlJobIdPos]]
+	allJobs.Unlock()
+	if(!existing) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
+	job.Status.Lock()
+	if(urlParts[urlServiceNamePos] == PAUSE) {
+		// Pausing the job if running: code 409 else
+		if(job.Status.State != STATE_RUNNING) {
+			job.Status.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		job.Process.Pause()
+		job.Status.State = STATE_PAUSED
+	} else {
+		// Resuming the job if paused: code 409 else
+		if(job.Status.State != STATE_PAUSED) {
+			job.Status.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		job.Process.Resume()
+		allJobs.pool.Notify()
+		job.Status.State = STATE_RUNNING
+	}
+	job.SaveJob(allJobs.store)
+	job.PublishStatus()
+	response := EncodeJson(job.Status)
+	job.Status.Unlock()
+	response.Write(w)
+}
+
+/* Job end points dispatching.
+This method shall route the HTTP requests on /jobs/... according to their method:
+- GET: getting job status and result, or streaming job events,
+- POST: pausing and resuming the job,
+- DELETE: cancelling the job.
+Else, code 405 shall be caught and displayed.
+*/
+func (allJobs *Jobs) HandleJob(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+		case http.MethodGet:
+			if(strings.HasSuffix(r.URL.Path, "/" + EVENTS)){
+				allJobs.StreamJobEvents(w, r)
+			} else {
+				allJobs.GetJobData(w, r)
+			}
+		case http.MethodPost:
+			allJobs.PauseResumeJob(w, r)
+		case http.MethodDelete:
+			allJobs.CancelJob(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+
+/* Loading jobs.
+This method shall restore in the allJobs specified receiver all the jobs saved in its store, with their definition, status, result, errors and HTTP
+status codes of their URLs.
+A job which was not over (completed, failed or cancelled) when saved shall be resumed from its saved checkpoint, each Job URL crawling process being
+restored and the job processed again, keeping its start and last activity times; a paused job shall stay paused until resumed. Without any checkpoint, the job shall be restored as interrupted.
+*/
+func (allJobs *Jobs) LoadJobs() {
+	jobIds, err := allJobs.store.List()
+	if err != nil {
+		fmt.Println("ERROR: Failed to list the stored jobs: " + err.Error())
+		return
+	}
+
+	allJobs.Lock()
+	defer allJobs.Unlock()
+	for _, jobId := range jobIds {
+		// Reading and decoding the job record, skipping it if corrupted
+		record := &JobRecord{}
+		bytes, err := allJobs.store.Load(jobId)
+		if err == nil {
+			err = json.Unmarshal(bytes, record)
+		}
+		if (err != nil) || (record.Def == nil) || (record.Status == nil) {
+			fmt.Println("ERROR: Failed to load Job_" + jobId)
+			continue
+		}
+		if(record.Result == nil){
+			record.Result = &JobResult{}
+		}
+		if(record.Errors == nil){
+			record.Errors = &JobErrors{}
+		}
+		if(record.Urls == nil){
+			record.Urls = &JobUrls{}
+		}
+
+		// Job over, or stopped before its end by the server shutdown without checkpoint: restored as is
+		over := (record.Status.State == STATE_COMPLETED) || (record.Status.State == STATE_FAILED) || (record.Status.State == STATE_CANCELLED)
+		if(over || (record.Frontier == nil)){
+			if(!over){
+				record.Status.State = STATE_INTERRUPTED
+			}
+			allJobs.jobs[jobId] = &Job{Def:record.Def, Status:record.Status, Result:record.Result, Errors:record.Errors, Urls:record.Urls}
+			continue
+		}
+
+		// Job stopped before its end by the server shutdown: resuming it from its checkpoint
+		job := &Job{}
+		job.InitJob(record.Def)
+		for jobUrl, checkpoint := range record.Frontier {
+			if jobUrlProcess, existing := job.Process.urlsProcesses[jobUrl]; existing {
+				jobUrlProcess.RestoreUrlProcess(checkpoint)
+			}
+		}
+		if(!record.Status.StartedAt.IsZero()){
+			job.Status.StartedAt = record.Status.StartedAt
+			job.Process.lastActivity = record.Status.LastActivityAt
+		}
+		if(record.Status.State == STATE_PAUSED){
+			job.Process.Pause()
+			job.Status.State = STATE_PAUSED
+		}
+		allJobs.jobs[jobId] = job
+		fmt.Println("Job_" + jobId + " resumed from its checkpoint")
+		go job.ProcessJob(allJobs.store, allJobs.history, allJobs.pool)
+	}
+}
+
+
+/* Saving schedule.
+This method shall save the definition and status of the specified receiver schedule as a ScheduleRecord in the specified store, unless deleted.
+It shall be called with the schedule status locked.
+*/
+func (schedule *Schedule) SaveSchedule(store Store) {
+	if(schedule.deleted){
+		return
+	}
+	record, err := json.Marshal(&ScheduleRecord{Def:schedule.Def, Status:schedule.Status})
+	if err == nil {
+		err = store.Save(schedule.Def.Schedule_id, record)
+	}
+	if err != nil {
+		fmt.Println("ERROR: Failed to save Schedule_" + schedule.Def.Schedule_id + ": " + err.Error())
+	}
+}
+
+/* Spawning a scheduled job.
+This method shall start a new job from the job definition of the specified schedule, through the same path as the jobs added in the entry point,
+record it in the history of the schedule, and return the channel closed once the job is over.
+*/
+func (allSchedules *Schedules) SpawnJob(schedule *Schedule) <-chan struct{} {
+	// Copying the job definition, so that each spawned job gets its own
+	jobDef := &JobDef{}
+	bytes, _ := json.Marshal(schedule.Def.Job)
+	json.Unmarshal(bytes, jobDef)
+	job := allSchedules.allJobs.StartJob(jobDef)
+	fmt.Println("Schedule_" + schedule.Def.Schedule_id + " spawned Job_" + jobDef.Job_id)
+
+	schedule.Status.Lock()
+	schedule.Status.Spawned = append(schedule.Status.Spawned, SpawnedJob{Job_id:jobDef.Job_id, Time:time.Now()})
+	schedule.SaveSchedule(allSchedules.store)
+	schedule.Status.Unlock()
+	return job.Done()
+}
+
+/* Running schedule.
+This method shall create the runner of the specified schedule, spawning its jobs with allSchedules specified receiver, and start it.
+The job spawned last shall be considered as the one of the last run, for the overlap policy.
+*/
+func (allSchedules *Schedules) RunSchedule(schedule *Schedule, expression Expression) {
+	var last <-chan struct{}
+	if spawned := schedule.Status.Spawned; len(spawned) > 0 {
+		allSchedules.allJobs.Lock()
+		if job, existing := allSchedules.allJobs.jobs[spawned[len(spawned) - 1].Job_id]; existing {
+			last = job.Done()
+		}
+		allSchedules.allJobs.Unlock()
+	}
+	skip := func() {
+		fmt.Println("Schedule_" + schedule.Def.Schedule_id + " skipped a tick")
+		schedule.Status.Lock()
+		schedule.Status.Skipped++
+		schedule.SaveSchedule(allSchedules.store)
+		schedule.Status.Unlock()
+	}
+	spawn := func() <-chan struct{} {
+		return allSchedules.SpawnJob(schedule)
+	}
+	next := func(tick time.Time) {
+		schedule.Status.Lock()
+		schedule.Status.NextRun = nil
+		if(!tick.IsZero()){
+			schedule.Status.NextRun = &tick
+		}
+		schedule.Status.Unlock()
+	}
+	schedule.runner = NewRunner(expression, schedule.Def.Overlap, last, spawn, skip)
+	go schedule.runner.Run(next)
+}
+
+/* Redacting a schedule definition.
+This method shall return a copy of the specified receiver scheduleDef to be displayed by the end points, with its job definition redacted (see
+JobDef.Redacted).
+*/
+func (scheduleDef *ScheduleDef) Redacted() *ScheduleDef {
+	redacted := *scheduleDef
+	if(redacted.Job != nil){
+		redacted.Job = redacted.Job.Redacted()
+	}
+	return &redacted
+}
+
+/* Adding schedule end point implementation.
+This method shall read the content of an HTTP request, and make sure that the request is JSON content type.
+The JSON decoded shall be a ScheduleDef structure type.
+If the request is not a JSON content type or malformed JSON, if the schedule expression is incorrect or never matches, if the overlap policy is incorrect, or if the job definition is
+missing or incorrect (see ApplyDefaults), code 400 shall be caught and displayed.
+Else, code 200 shall be caught and displayed, and following shall be performed:
+- create a unique schedule_id value,
+- use the skip overlap policy if none specified,
+- add the new schedule to the allSchedules specified receiver, save it in its store, and start spawning its jobs,
+- display the response as a new JSON of ScheduleRecord type, with the schedule definition completed and its status.
+*/
+func (allSchedules *Schedules) AddSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleDef := &ScheduleDef{}
+
+	// Reading the HTTP request content
+	bytes, _ := ioutil.ReadAll(r.Body)
+
+	// Decoding and checking the JSON content: code 400 is incorrect
+	var expression Expression
+	ct := r.Header.Get("content-type")
+	err := json.Unmarshal(bytes, scheduleDef)
+	if (err == nil) && (ct != "application/json") {
+		err = errors.New("content-type shall be application/json")
+	}
+	if err == nil {
+		expression, err = ParseExpression(scheduleDef.Expression)
+	}
+	if (err == nil) && expression.Next(time.Now()).IsZero() {
+		err = errors.New("schedule expression never matches: " + scheduleDef.Expression)
+	}
+	if(scheduleDef.Overlap == ""){
+		scheduleDef.Overlap = OVERLAP_SKIP
+	}
+	if err == nil {
+		err = CheckOverlap(scheduleDef.Overlap)
+	}
+	if (err == nil) && (scheduleDef.Job == nil) {
+		err = errors.New("job definition shall be specified")
+	}
+	if err == nil {
+		err = scheduleDef.Job.ApplyDefaults()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Creating a unique schedule_id, the job_id being given to each spawned job
+	scheduleDef.Schedule_id = fmt.Sprintf("%d", time.Now().UnixNano())
+	scheduleDef.Job.Job_id = ""
+
+	// Adding the new schedule to allSchedules, and starting it
+	schedule := &Schedule{Def:scheduleDef, Status:&ScheduleStatus{Spawned:[]SpawnedJob{}}}
+	allSchedules.Lock()
+	allSchedules.schedules[scheduleDef.Schedule_id] = schedule
+	allSchedules.Unlock()
+	schedule.Status.Lock()
+	schedule.SaveSchedule(allSchedules.store)
+	schedule.Status.Unlock()
+	allSchedules.RunSchedule(schedule, expression)
+
+	// Displaying the JSON response with schedule_id defined, and code 200 if success
+	schedule.Status.Lock()
+	response := EncodeJson(&ScheduleRecord{Def:scheduleDef.Redacted(), Status:schedule.Status})
+	schedule.Status.Unlock()
+	response.Write(w)
+}
+
+/* Listing schedules end point implementation.
+This method shall display, with code 200, the response as a JSON list of ScheduleRecord type, for all the schedules of the allSchedules specified receiver.
+*/
+func (allSchedules *Schedules) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	allSchedules.Lock()
+	records := []json.RawMessage{}
+	for _, schedule := range allSchedules.schedules {
+		// Reading the runner before locking the status, the runner spawning its jobs under the status lock
+		queued := schedule.runner.Queued()
+		// Encoding each record while its status is locked
+		schedule.Status.Lock()
+		schedule.Status.Queued = queued
+		record, err := json.Marshal(&ScheduleRecord{Def:schedule.Def.Redacted(), Status:schedule.Status})
+		schedule.Status.Unlock()
+		if err == nil {
+			records = append(records, record)
+		}
+	}
+	allSchedules.Unlock()
+	WriteJson(w, records)
+}
+
+/* Schedule end points implementation.
+This method shall read the content of an HTTP request, and make sure that the HTTP request is composed by the synthaxis /schedules/{schedule_id}.
+If schedule_id is not existing among the allSchedules specified receiver parameter, code 404 shall be caught and displayed.
+Else, according to the method of the request, code 200 shall be caught and displayed, and following shall be performed:
+- GET: display the response as a new JSON of ScheduleRecord type, with the definition and status of the schedule,
+- DELETE: stop the schedule, so that no job is spawned anymore, and remove it from allSchedules and its store; the spawned jobs shall be kept.
+Else, code 405 shall be caught and displayed.
+*/
+func (allSchedules *Schedules) HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	urlScheduleIdPos := 2
+
+	// Reading and splitting the URL given in the request
+	urlParts := strings.Split(r.URL.Path, "/")
+	if(len(urlParts) != 3) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Trying to retrieve the requested schedule among the schedules datastore
+	allSchedules.Lock()
+	schedule, existing := allSchedules.schedules[urlParts[urlScheduleIdPos]]
+	if(existing && (r.Method == http.MethodDelete)) {
+		delete(allSchedules.schedules, schedule.Def.Schedule_id)
+	}
+	allSchedules.Unlock()
+	if(!existing) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+		case http.MethodGet:
+			queued := schedule.runner.Queued()
+			schedule.Status.Lock()
+			schedule.Status.Queued = queued
+			response := EncodeJson(&ScheduleRecord{Def:schedule.Def.Redacted(), Status:schedule.Status})
+			schedule.Status.Unlock()
+			response.Write(w)
+		case http.MethodDelete:
+			schedule.runner.Stop()
+			schedule.Status.Lock()
+			schedule.deleted = true
+			if err := allSchedules.store.Delete(schedule.Def.Schedule_id); err != nil {
+				fmt.Println("ERROR: Failed to delete Schedule_" + schedule.Def.Schedule_id + ": " + err.Error())
+			}
+			schedule.Status.NextRun = nil
+			schedule.Status.Queued = false
+			response := EncodeJson(&ScheduleRecord{Def:schedule.Def.Redacted(), Status:schedule.Status})
+			schedule.Status.Unlock()
+			response.Write(w)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+/* Schedules end point dispatching.
+This method shall route the HTTP requests on /schedules according to their method:
+- GET: listing the schedules,
+- POST: adding a schedule.
+Else, code 405 shall be caught and displayed.
+*/
+func (allSchedules *Schedules) HandleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+		case http.MethodGet:
+			allSchedules.ListSchedules(w, r)
+		case http.MethodPost:
+			allSchedules.AddSchedule(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+/* Loading schedules.
+This method shall restore in the allSchedules specified receiver all the schedules saved in its store, with their definition and status, and start them
+again. A job queued when the schedules were saved shall not be spawned.
+*/
+func (allSchedules *Schedules) LoadSchedules() {
+	scheduleIds, err := allSchedules.store.List()
+	if err != nil {
+		fmt.Println("ERROR: Failed to list the stored schedules: " + err.Error())
+		return
+	}
+
+	allSchedules.Lock()
+	defer allSchedules.Unlock()
+	for _, scheduleId := range scheduleIds {
+		// Reading and decoding the schedule record, skipping it if corrupted
+		record := &ScheduleRecord{}
+		bytes, err := allSchedules.store.Load(scheduleId)
+		if err == nil {
+			err = json.Unmarshal(bytes, record)
+		}
+		var expression Expression
+		if (err == nil) && (record.Def != nil) && (record.Status != nil) && (record.Def.Job != nil) {
+			expression, err = ParseExpression(record.Def.Expression)
+		}
+		if (err != nil) || (expression == nil) {
+			fmt.Println("ERROR: Failed to load Schedule_" + scheduleId)
+			continue
+		}
+		record.Status.Queued = false
+		schedule := &Schedule{Def:record.Def, Status:record.Status}
+		allSchedules.schedules[scheduleId] = schedule
+		allSchedules.RunSchedule(schedule, expression)
+	}
 }
 
 
 /* Entry point of the API*/
 func main() {
+	storeDir := flag.String("store", STORE_DIR, "directory of the persistent job store")
+	poolSize := flag.Int("workers", DEFAULT_POOL_SIZE, "number of workers shared by all the jobs, indeed maximum number of concurrent crawls")
+	fetchCacheMaxBytes := flag.Int64("fetch-cache-max-bytes", FETCH_CACHE_MAX_BYTES, "maximum number of body bytes of the crawled pages kept by the fetch cache")
+	flag.Parse()
+	SetFetchCacheMaxSize(*fetchCacheMaxBytes)
 
-	allJobs := Jobs{ jobs : map[string]*Job{} }
+	// Opening the job store, and restoring the jobs saved in it
+	store, err := NewFileStore(*storeDir)
+	if err != nil {
+		fmt.Println("ERROR: Failed to open the job store: " + err.Error())
+		return
+	}
+	history, err := NewFileStore(filepath.Join(*storeDir, HISTORY_DIR))
+	if err != nil {
+		fmt.Println("ERROR: Failed to open the crawl history store: " + err.Error())
+		return
+	}
+	schedulesStore, err := NewFileStore(filepath.Join(*storeDir, SCHEDULES_DIR))
+	if err != nil {
+		fmt.Println("ERROR: Failed to open the schedule store: " + err.Error())
+		return
+	}
+	allJobs := Jobs{ jobs : map[string]*Job{}, store : store, history : history, pool : NewPool(*poolSize) }
+	allJobs.LoadJobs()
+	allSchedules := Schedules{ schedules : map[string]*Schedule{}, store : schedulesStore, allJobs : &allJobs }
+	allSchedules.LoadSchedules()
 
 	// Adding a Job end point
 	http.HandleFunc("/jobs", allJobs.AddJob)
-	// Getting a Job status and result end point
-	http.HandleFunc("/jobs/", allJobs.GetJobData)
+	// Getting a Job status and result, pausing, resuming and cancelling a Job end points
+	http.HandleFunc("/jobs/", allJobs.HandleJob)
+	// Adding and listing Schedules end points
+	http.HandleFunc("/schedules", allSchedules.HandleSchedules)
+	// Getting and deleting a Schedule end points
+	http.HandleFunc("/schedules/", allSchedules.HandleSchedule)
 
 	// Opening URL connection on http://localhost:PORT
     http.ListenAndServe(PORT, nil)
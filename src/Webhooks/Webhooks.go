@@ -0,0 +1,159 @@
+package Webhooks
+
+import (
+   "bytes"
+   "crypto/hmac"
+   "crypto/sha256"
+   "encoding/hex"
+   "fmt"
+   "net/http"
+   "strconv"
+   "time"
+)
+
+const SIGNATURE_HEADER = "X-Webhook-Signature" // "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" with the secret
+const TIMESTAMP_HEADER = "X-Webhook-Timestamp" // Unix time of the delivery attempt, part of the signed content
+const EVENT_HEADER = "X-Webhook-Event"
+const SIGNATURE_PREFIX = "sha256="
+const MAX_ATTEMPTS = 6 // Maximum number of delivery attempts of an event
+const INITIAL_BACKOFF = time.Second // Delay before the first retry, doubled at each retry
+const MAX_BACKOFF = time.Minute
+const DELIVERY_TIMEOUT = 10 * time.Second
+const QUEUE_SIZE = 16 // Number of events waiting for delivery before Notify blocks
+
+/* Event waiting for delivery: name and JSON encoded payload */
+type delivery struct {
+   event string
+   payload []byte
+}
+
+/* Notifier delivering the events of a source (a job for instance) to its callback URL, one at a time in their order:
+- url: callback URL the events are POSTed to,
+- secret: secret key of the HMAC signature of the deliveries, the deliveries being sent without signature header if empty,
+- events: names of the events delivered (keys), all if empty,
+- backoff: delay before the first retry of a delivery, doubled at each retry,
+- queue: events waiting for delivery, closed once no more event is to come,
+- done: channel closed once all the events are delivered or given up.*/
+type Notifier struct {
+   url string
+   secret string
+   events map[string]bool
+   backoff time.Duration
+   client *http.Client
+   queue chan delivery
+   done chan struct{}
+}
+
+
+
+/* Notifier creation.
+This method shall create the notifier of the specified callback URL, signing the deliveries with the specified secret (unsigned if empty), and start
+delivering its events, only those of the specified names if any.
+*/
+func NewNotifier(url string, secret string, events []string) *Notifier {
+   return newNotifier(url, secret, events, INITIAL_BACKOFF)
+}
+
+// Helper function to create and start a notifier which retries start after the specified backoff
+func newNotifier(url string, secret string, events []string, backoff time.Duration) *Notifier {
+   notifier := &Notifier{url:url, secret:secret, events:map[string]bool{}, backoff:backoff, client:&http.Client{Timeout:DELIVERY_TIMEOUT},
+      queue:make(chan delivery, QUEUE_SIZE), done:make(chan struct{})}
+   for _, event := range events {
+      notifier.events[event] = true
+   }
+   go notifier.deliver()
+   return notifier
+}
+
+/* Signing a payload.
+This method shall return the signature of the specified payload delivered at the specified Unix time with the specified secret,
+as sent in the signature header: "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<payload>".
+*/
+func Sign(secret string, timestamp string, payload []byte) string {
+   mac := hmac.New(sha256.New, []byte(secret))
+   mac.Write([]byte(timestamp + "."))
+   mac.Write(payload)
+   return SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+/* Verifying a signature.
+This method shall return whether the specified signature, as received in the signature header, is the one of the specified payload delivered at the
+specified Unix time with the specified secret, compared in constant time.
+*/
+func Verify(secret string, timestamp string, payload []byte, signature string) bool {
+   return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, payload)))
+}
+
+/* Notifying an event.
+This method shall queue the specified event with its JSON encoded payload for delivery by the specified receiver notifier, unless the notifier
+delivers other events only.
+*/
+func (notifier *Notifier) Notify(event string, payload []byte) {
+   if (len(notifier.events) > 0) && !notifier.events[event] {
+      return
+   }
+   notifier.queue <- delivery{event:event, payload:payload}
+}
+
+/* Closing the notifier.
+This method shall make the specified receiver notifier stop once the events already queued are delivered or given up, and return a channel closed then.
+No event shall be notified after.
+*/
+func (notifier *Notifier) Close() <-chan struct{} {
+   close(notifier.queue)
+   return notifier.done
+}
+
+/* Notifier in action.
+This method shall deliver the queued events of the specified receiver notifier in their order, each event being retried with an exponential backoff
+until delivered or given up (see attempt), and end once the queue is closed and empty.
+*/
+func (notifier *Notifier) deliver() {
+   defer close(notifier.done)
+   for event := range notifier.queue {
+      backoff := notifier.backoff
+      for attempt := 1; ; attempt++ {
+         retry, err := notifier.attempt(event)
+         if err == nil {
+            break
+         }
+         if !retry || (attempt >= MAX_ATTEMPTS) {
+            fmt.Println("ERROR: Failed to deliver " + event.event + " event to " + notifier.url + ": " + err.Error())
+            break
+         }
+         time.Sleep(backoff)
+         backoff *= 2
+         if backoff > MAX_BACKOFF {
+            backoff = MAX_BACKOFF
+         }
+      }
+   }
+}
+
+// Helper function to POST an event once to the callback URL, returning whether the delivery is worth retrying if failed:
+// on network errors, 408, 429 and 5xx responses, the other responses than 2xx being definitive failures
+func (notifier *Notifier) attempt(event delivery) (bool, error) {
+   request, err := http.NewRequest(http.MethodPost, notifier.url, bytes.NewReader(event.payload))
+   if err != nil {
+      return false, err
+   }
+   timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+   request.Header.Set("Content-Type", "application/json")
+   request.Header.Set(EVENT_HEADER, event.event)
+   request.Header.Set(TIMESTAMP_HEADER, timestamp)
+   if notifier.secret != "" {
+      request.Header.Set(SIGNATURE_HEADER, Sign(notifier.secret, timestamp, event.payload))
+   }
+   response, err := notifier.client.Do(request)
+   if err != nil {
+      return true, err
+   }
+   response.Body.Close()
+   switch {
+      case (response.StatusCode >= 200) && (response.StatusCode < 300):
+         return false, nil
+      case (response.StatusCode == http.StatusRequestTimeout) || (response.StatusCode == http.StatusTooManyRequests) || (response.StatusCode >= 500):
+         return true, fmt.Errorf("status %d", response.StatusCode)
+   }
+   return false, fmt.Errorf("status %d", response.StatusCode)
+}
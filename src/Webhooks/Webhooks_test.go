@@ -0,0 +1,159 @@
+package Webhooks
+
+import (
+   "io/ioutil"
+   "net/http"
+   "net/http/httptest"
+   "sync"
+   "testing"
+   "time"
+)
+
+/* Delivery received by a test receiver */
+type received struct {
+   event string
+   timestamp string
+   signature string
+   payload []byte
+}
+
+// Helper function to start a receiver answering the deliveries with the specified status codes in turn, 200 once exhausted, and recording them
+func newTestReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+   var lock sync.Mutex
+   deliveries := []received{}
+   server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      payload, _ := ioutil.ReadAll(r.Body)
+      lock.Lock()
+      defer lock.Unlock()
+      deliveries = append(deliveries, received{event:r.Header.Get(EVENT_HEADER), timestamp:r.Header.Get(TIMESTAMP_HEADER),
+         signature:r.Header.Get(SIGNATURE_HEADER), payload:payload})
+      if len(deliveries) <= len(statuses) {
+         w.WriteHeader(statuses[len(deliveries) - 1])
+      }
+   }))
+   t.Cleanup(server.Close)
+   return server, func() []received {
+      lock.Lock()
+      defer lock.Unlock()
+      return append([]received{}, deliveries...)
+   }
+}
+
+// Helper function to close the specified notifier, failing if its deliveries do not end in time
+func closeTestNotifier(t *testing.T, notifier *Notifier) {
+   select {
+      case <-notifier.Close():
+      case <-time.After(10 * time.Second):
+         t.Fatal("deliveries not over in time")
+   }
+}
+
+func TestSign(t *testing.T) {
+   payload := []byte(`{"event":"job.completed"}`)
+   signature := Sign("secret", "1700000000", payload)
+   if want := "sha256=e33f34cc0b46f4e752fe75a10d7177366fd795c052ed09dfa63608265c13be69"; signature != want {
+      t.Errorf("Sign = %s, want %s", signature, want)
+   }
+
+   tests := []struct {
+      name string
+      secret string
+      timestamp string
+      payload string
+      signature string
+      want bool
+   }{
+      {"valid", "secret", "1700000000", string(payload), signature, true},
+      {"wrong secret", "other", "1700000000", string(payload), signature, false},
+      {"wrong timestamp", "secret", "1700000001", string(payload), signature, false},
+      {"tampered payload", "secret", "1700000000", `{"event":"job.failed"}`, signature, false},
+      {"missing prefix", "secret", "1700000000", string(payload), signature[len(SIGNATURE_PREFIX):], false},
+      {"empty", "secret", "1700000000", string(payload), "", false},
+   }
+   for _, test := range tests {
+      if got := Verify(test.secret, test.timestamp, []byte(test.payload), test.signature); got != test.want {
+         t.Errorf("%s: Verify = %v, want %v", test.name, got, test.want)
+      }
+   }
+}
+
+func TestNotifierSigned(t *testing.T) {
+   server, deliveries := newTestReceiver(t)
+   notifier := newNotifier(server.URL, "secret", nil, time.Millisecond)
+   notifier.Notify("job.started", []byte(`{"event":"job.started"}`))
+   notifier.Notify("job.completed", []byte(`{"event":"job.completed"}`))
+   closeTestNotifier(t, notifier)
+
+   got := deliveries()
+   if (len(got) != 2) || (got[0].event != "job.started") || (got[1].event != "job.completed") {
+      t.Fatalf("deliveries %+v, want job.started then job.completed", got)
+   }
+   for _, delivery := range got {
+      if !Verify("secret", delivery.timestamp, delivery.payload, delivery.signature) {
+         t.Errorf("%s delivery: signature %q not verified", delivery.event, delivery.signature)
+      }
+   }
+}
+
+func TestNotifierUnsigned(t *testing.T) {
+   server, deliveries := newTestReceiver(t)
+   notifier := newNotifier(server.URL, "", nil, time.Millisecond)
+   notifier.Notify("job.started", []byte(`{}`))
+   closeTestNotifier(t, notifier)
+   if got := deliveries(); (len(got) != 1) || (got[0].signature != "") || (got[0].timestamp == "") {
+      t.Errorf("deliveries %+v, want one with a timestamp and without signature", got)
+   }
+}
+
+func TestNotifierRetry(t *testing.T) {
+   tests := []struct {
+      name string
+      statuses []int
+      attempts int
+   }{
+      {"delivered", nil, 1},
+      {"retried on 5xx", []int{http.StatusServiceUnavailable, http.StatusInternalServerError}, 3},
+      {"retried on 429", []int{http.StatusTooManyRequests}, 2},
+      {"not retried on 4xx", []int{http.StatusBadRequest}, 1},
+      {"given up", []int{500, 500, 500, 500, 500, 500, 500}, MAX_ATTEMPTS},
+   }
+   for _, test := range tests {
+      server, deliveries := newTestReceiver(t, test.statuses...)
+      notifier := newNotifier(server.URL, "secret", nil, time.Millisecond)
+      notifier.Notify("job.completed", []byte(`{}`))
+      closeTestNotifier(t, notifier)
+      if got := len(deliveries()); got != test.attempts {
+         t.Errorf("%s: %d attempts, want %d", test.name, got, test.attempts)
+      }
+   }
+}
+
+func TestNotifierEvents(t *testing.T) {
+   tests := []struct {
+      name string
+      events []string
+      want []string
+   }{
+      {"all", nil, []string{"job.started", "job.failed", "job.completed"}},
+      {"filtered", []string{"job.completed", "job.failed"}, []string{"job.failed", "job.completed"}},
+      {"none matching", []string{"job.cancelled"}, []string{}},
+   }
+   for _, test := range tests {
+      server, deliveries := newTestReceiver(t)
+      notifier := newNotifier(server.URL, "secret", test.events, time.Millisecond)
+      for _, event := range []string{"job.started", "job.failed", "job.completed"} {
+         notifier.Notify(event, []byte(`{}`))
+      }
+      closeTestNotifier(t, notifier)
+      got := deliveries()
+      if len(got) != len(test.want) {
+         t.Errorf("%s: %d deliveries, want %v", test.name, len(got), test.want)
+         continue
+      }
+      for i, delivery := range got {
+         if delivery.event != test.want[i] {
+            t.Errorf("%s: delivery %d is %s, want %s", test.name, i, delivery.event, test.want[i])
+         }
+      }
+   }
+}
@@ -0,0 +1,147 @@
+package WorkerPool
+
+import (
+   "sync"
+)
+
+/* Source of tasks for the pool workers (a job for instance).
+NextTask shall return the next task to perform, or false if the source has no task available for now. It is called with the pool locked,
+so it shall not call back the pool. */
+type TaskSource interface {
+   NextTask() (func(), bool)
+}
+
+/* Task source registered in the pool:
+- source: registered task source,
+- share: maximum number of workers performing tasks of the source at the same time,
+- running: number of workers performing tasks of the source.*/
+type sourceEntry struct {
+   source TaskSource
+   share int
+   running int
+}
+
+/* Pool of workers shared by all the task sources:
+- size: number of workers, indeed maximum number of tasks performed at the same time,
+- sources: registered task sources, served in turn,
+- next: position in sources of the next task source to serve,
+- wakeup: condition signaled when tasks may have become available for the idle workers.*/
+type Pool struct {
+   sync.Mutex
+   size int
+   sources []*sourceEntry
+   next int
+   wakeup *sync.Cond
+}
+
+
+
+/* Pool creation.
+This method shall create a pool, and start the specified number of workers (at least 1) waiting for tasks.
+*/
+func NewPool(size int) *Pool {
+   if size < 1 {
+      size = 1
+   }
+   pool := &Pool{size:size}
+   pool.wakeup = sync.NewCond(pool)
+   for i := 0; i < size; i++ {
+      go pool.work()
+   }
+   return pool
+}
+
+/* Getting pool size.
+This method shall return the number of workers of the specified receiver pool.
+*/
+func (pool *Pool) Size() int {
+   return pool.size
+}
+
+/* Registering a task source.
+This method shall register the specified task source in the specified receiver pool, so that the workers perform its tasks,
+with at most the specified share of workers at the same time (at least 1).
+*/
+func (pool *Pool) Register(source TaskSource, share int) {
+   if share < 1 {
+      share = 1
+   }
+   pool.Lock()
+   pool.sources = append(pool.sources, &sourceEntry{source:source, share:share})
+   pool.Unlock()
+   pool.Notify()
+}
+
+/* Unregistering a task source.
+This method shall remove the specified task source from the specified receiver pool: no new task of the source shall be performed,
+the tasks being performed going on until their end.
+*/
+func (pool *Pool) Unregister(source TaskSource) {
+   pool.Lock()
+   defer pool.Unlock()
+   for i, entry := range pool.sources {
+      if entry.source == source {
+         pool.sources = append(pool.sources[:i], pool.sources[i+1:]...)
+         if pool.next > i {
+            pool.next--
+         }
+         return
+      }
+   }
+}
+
+/* Notifying the pool.
+This method shall wake up the idle workers of the specified receiver pool, since new tasks may be available.
+*/
+func (pool *Pool) Notify() {
+   pool.Lock()
+   pool.wakeup.Broadcast()
+   pool.Unlock()
+}
+
+/* Selecting the next task.
+This method shall look for the next task to perform, serving the task sources in turn starting from the one following the last served,
+and skipping the task sources which share of workers is reached, so that every task source gets workers whatever the others.
+It shall be called with the pool locked.
+*/
+func (pool *Pool) nextTask() (*sourceEntry, func()) {
+   nbSources := len(pool.sources)
+   for i := 0; i < nbSources; i++ {
+      position := (pool.next + i) % nbSources
+      entry := pool.sources[position]
+      if entry.running >= entry.share {
+         continue
+      }
+      if task, available := entry.source.NextTask(); available {
+         pool.next = (position + 1) % nbSources
+         return entry, task
+      }
+   }
+   return nil, nil
+}
+
+/* Worker in action.
+This method shall define the work cycle of a pool worker: performing the next task available, sleeping until notified else.
+The idle workers shall be woken up when a task ends only if it releases a share of workers reached; new tasks shall be notified by their sources.
+*/
+func (pool *Pool) work() {
+   pool.Lock()
+   for {
+      entry, task := pool.nextTask()
+      if task == nil {
+         // No task available: waiting for a notification
+         pool.wakeup.Wait()
+         continue
+      }
+
+      entry.running++
+      pool.Unlock()
+      task()
+      pool.Lock()
+      if entry.running >= entry.share {
+         // The ended task releases a share of workers reached, possibly with tasks left
+         pool.wakeup.Broadcast()
+      }
+      entry.running--
+   }
+}
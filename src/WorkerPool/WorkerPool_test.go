@@ -0,0 +1,203 @@
+package WorkerPool
+
+import (
+   "sync"
+   "testing"
+   "time"
+)
+
+/* Task source of the tests: name of the source, and tasks left to perform */
+type testSource struct {
+   sync.Mutex
+   name string
+   tasks []func()
+}
+
+// Helper function to create a task source with the specified number of tasks, each one performing the specified function with the source name
+func newTestSource(name string, nbTasks int, perform func(name string)) *testSource {
+   source := &testSource{name:name}
+   for i := 0; i < nbTasks; i++ {
+      source.add(func() { perform(name) })
+   }
+   return source
+}
+
+// Helper function to add a task to a test source
+func (source *testSource) add(task func()) {
+   source.Lock()
+   source.tasks = append(source.tasks, task)
+   source.Unlock()
+}
+
+func (source *testSource) NextTask() (func(), bool) {
+   source.Lock()
+   defer source.Unlock()
+   if len(source.tasks) == 0 {
+      return nil, false
+   }
+   task := source.tasks[0]
+   source.tasks = source.tasks[1:]
+   return task, true
+}
+
+// Helper function to wait for the specified channel to be signaled, failing if it is not in time
+func waitSignal(t *testing.T, signal <-chan struct{}, what string) {
+   t.Helper()
+   select {
+      case <-signal:
+      case <-time.After(5 * time.Second):
+         t.Fatal(what + " not signaled in time")
+   }
+}
+
+func TestPoolShare(t *testing.T) {
+   tests := []struct {
+      size int
+      share int
+      want int
+   }{
+      {4, 2, 2},
+      {2, 4, 2},
+      {4, 0, 1},
+   }
+   for _, test := range tests {
+      var lock sync.Mutex
+      running, maxRunning := 0, 0
+      started := make(chan struct{}, 10)
+      release := make(chan struct{})
+      source := newTestSource("a", 10, func(string) {
+         lock.Lock()
+         running++
+         if running > maxRunning {
+            maxRunning = running
+         }
+         lock.Unlock()
+         started <- struct{}{}
+         <-release
+         lock.Lock()
+         running--
+         lock.Unlock()
+      })
+      pool := NewPool(test.size)
+      pool.Register(source, test.share)
+
+      for i := 0; i < test.want; i++ {
+         waitSignal(t, started, "task start")
+      }
+      // Leaving time to the other workers to start more tasks, if they could
+      time.Sleep(100 * time.Millisecond)
+      close(release)
+      for i := test.want; i < 10; i++ {
+         waitSignal(t, started, "task start")
+      }
+      pool.Unregister(source)
+
+      lock.Lock()
+      if maxRunning != test.want {
+         t.Errorf("pool of %d workers, share %d: %d tasks at the same time, want %d", test.size, test.share, maxRunning, test.want)
+      }
+      lock.Unlock()
+   }
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+   var lock sync.Mutex
+   order := []string{}
+   done := make(chan struct{}, 9)
+   perform := func(name string) {
+      lock.Lock()
+      order = append(order, name)
+      lock.Unlock()
+      done <- struct{}{}
+   }
+
+   // Sources registered before their tasks are added, so that the single worker serves them all from the start
+   pool := NewPool(1)
+   sources := []*testSource{newTestSource("a", 0, perform), newTestSource("b", 0, perform), newTestSource("c", 0, perform)}
+   for _, source := range sources {
+      pool.Register(source, 1)
+   }
+   pool.Lock()
+   for _, source := range sources {
+      for i := 0; i < 3; i++ {
+         name := source.name
+         source.add(func() { perform(name) })
+      }
+   }
+   pool.Unlock()
+   pool.Notify()
+   for i := 0; i < 9; i++ {
+      waitSignal(t, done, "task end")
+   }
+
+   lock.Lock()
+   defer lock.Unlock()
+   // Each source served in turn, whichever is served first
+   for i := 3; i < len(order); i++ {
+      if order[i] != order[i - 3] {
+         t.Fatalf("tasks performed in order %v, want the sources served in turn", order)
+      }
+   }
+   if (order[0] == order[1]) || (order[1] == order[2]) || (order[0] == order[2]) {
+      t.Errorf("tasks performed in order %v, want the sources served in turn", order)
+   }
+}
+
+func TestPoolUnregisterRunning(t *testing.T) {
+   started := make(chan struct{}, 10)
+   release := make(chan struct{})
+   performed := 0
+   var lock sync.Mutex
+   source := newTestSource("a", 10, func(string) {
+      started <- struct{}{}
+      <-release
+      lock.Lock()
+      performed++
+      lock.Unlock()
+   })
+   ended := make(chan struct{})
+   other := newTestSource("b", 0, nil)
+
+   pool := NewPool(2)
+   pool.Register(source, 2)
+   waitSignal(t, started, "task start")
+   waitSignal(t, started, "task start")
+
+   // Unregistering while 2 tasks are running: both going on until their end, and no other task started
+   pool.Unregister(source)
+   close(release)
+   other.add(func() { close(ended) })
+   pool.Register(other, 1)
+   waitSignal(t, ended, "task of another source")
+   time.Sleep(100 * time.Millisecond)
+
+   lock.Lock()
+   defer lock.Unlock()
+   if performed != 2 {
+      t.Errorf("%d tasks performed by the unregistered source, want the 2 running ones", performed)
+   }
+   source.Lock()
+   left := len(source.tasks)
+   source.Unlock()
+   if left != 8 {
+      t.Errorf("%d tasks left to the unregistered source, want 8", left)
+   }
+}
+
+func TestPoolNotify(t *testing.T) {
+   performed := make(chan struct{}, 1)
+   source := newTestSource("a", 0, nil)
+   pool := NewPool(2)
+   pool.Register(source, 2)
+   // Leaving time to the workers to find no task and sleep
+   time.Sleep(50 * time.Millisecond)
+
+   source.add(func() { performed <- struct{}{} })
+   select {
+      case <-performed:
+         t.Fatal("task performed by an idle worker before being notified")
+      case <-time.After(100 * time.Millisecond):
+   }
+   pool.Notify()
+   waitSignal(t, performed, "task notified")
+}